@@ -23,16 +23,342 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/xkapastel/go-abc/pkg/abc"
+	"github.com/xkapastel/go-abc/pkg/abc/lambda"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
+// layout is how programs are written for people to read.
+var layout = abc.Options{Width: 80, Indent: 2}
+
 func main() {
+	command := "eval"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "eval":
+		eval()
+	case "check":
+		check()
+	case "explain":
+		explain()
+	case "effect":
+		effect()
+	case "fmt":
+		format()
+	case "build":
+		build()
+	case "gen-go":
+		genGo()
+	case "bench":
+		bench()
+	case "conform":
+		conform()
+	default:
+		fmt.Fprintf(os.Stderr, "abc: unknown command `%s`\n", command)
+		os.Exit(2)
+	}
+}
+
+// eval rewrites a program from stdin, printing the result, and
+// explaining on stderr why any code left in the result got stuck.
+// Rules read from the files named on the command line simplify the
+// result, and each rule is noted on stderr when it is applied.
+func eval() {
 	const defaultQuota = 1000
 	stdin := bufio.NewReader(os.Stdin)
 	lhs, err := abc.Read(stdin)
 	if err != nil {
 		panic(err)
 	}
-	rhs := abc.Rewrite(lhs, defaultQuota)
-	fmt.Println(rhs)
+	config := abc.Config{
+		Quota: defaultQuota,
+		Trace: true,
+		Stuck: func(stuck abc.Stuck) {
+			fmt.Fprintf(os.Stderr, "abc: %s\n", stuck)
+		},
+	}
+	if len(os.Args) > 2 {
+		config.Rules, err = loadRules(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	rhs := abc.RewriteWith(lhs, config)
+	fmt.Println(abc.Format(rhs, layout))
+}
+
+// loadRules reads and checks rule files, returning an optimizer that
+// notes each rule it applies on stderr.
+func loadRules(paths []string) (*abc.Optimizer, error) {
+	var rules []abc.Rule
+	for _, path := range paths {
+		more, err := abc.ReadRulesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, more...)
+	}
+	err := abc.CheckRules(rules...)
+	if err != nil {
+		return nil, err
+	}
+	optimizer, err := abc.NewOptimizer(rules...)
+	if err != nil {
+		return nil, err
+	}
+	optimizer.Trace = func(rule abc.Rule, code abc.Object) {
+		parts := abc.Parts(code)
+		src := abc.OriginOf(parts[len(parts)-1])
+		if src == nil {
+			fmt.Fprintf(os.Stderr, "abc: `%s` by rule `%s`\n", code, rule)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "abc: %s: `%s` by rule `%s`\n", src, code, rule)
+	}
+	return optimizer, nil
+}
+
+// check prints the stack effect of a program from stdin, or where
+// the program would get stuck. Given the names of words, it checks
+// their definitions instead, including any type annotations.
+func check() {
+	if len(os.Args) > 2 {
+		failed := false
+		for _, name := range os.Args[2:] {
+			object, err := abc.Read(strings.NewReader(name))
+			if err == nil {
+				err = checkWord(name, object)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+	stdin := bufio.NewReader(os.Stdin)
+	object, err := abc.Read(stdin)
+	if err != nil {
+		panic(err)
+	}
+	effect, err := abc.Infer(object)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(effect)
+}
+
+// format lays out the source files named on the command line in
+// place, or copies a program from stdin to stdout when there are
+// none.
+func format() {
+	if len(os.Args) <= 2 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			panic(err)
+		}
+		out, err := abc.FormatSource(string(src), layout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
+	}
+	failed := false
+	for _, path := range os.Args[2:] {
+		err := formatFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "abc: %s: %s\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := abc.FormatSource(string(src), layout)
+	if err != nil || out == string(src) {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(out), info.Mode())
+}
+
+// explain prints a program from stdin in terms of named variables.
+func explain() {
+	stdin := bufio.NewReader(os.Stdin)
+	object, err := abc.Read(stdin)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(lambda.Explain(object))
+}
+
+// effect prints what a program from stdin does to boxes of unknown
+// code, such as `[X] [Y] -> [Y] [X]` for `f`.
+func effect() {
+	const defaultQuota = 1000
+	stdin := bufio.NewReader(os.Stdin)
+	object, err := abc.Read(stdin)
+	if err != nil {
+		panic(err)
+	}
+	config := abc.Config{Quota: defaultQuota}
+	fmt.Println(abc.Symbolic(object, config))
+}
+
+// build specializes the word named on the command line to the
+// arguments read from stdin. The result is written to a file named
+// for the word and the result's hash, so that it can be called as a
+// word in place of the arguments and the original word, and the new
+// word is printed.
+func build() {
+	const defaultQuota = 10000
+	if len(os.Args) != 3 {
+		fmt.Fprintf(os.Stderr, "abc: build takes the name of a word\n")
+		os.Exit(2)
+	}
+	word := os.Args[2]
+	stdin := bufio.NewReader(os.Stdin)
+	args, err := abc.Read(stdin)
+	if err != nil {
+		panic(err)
+	}
+	ctx := abc.Specializer{Quota: defaultQuota}
+	object, err := ctx.Specialize(word, abc.Parts(args)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "abc: %s: %s\n", word, err)
+		os.Exit(1)
+	}
+	hash := abc.HashOf(object)
+	name := fmt.Sprintf("%s-%x", word, hash[:6])
+	src := abc.Format(object, layout) + "\n"
+	err = ioutil.WriteFile(name, []byte(src), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(name)
+}
+
+// genGo prints a Go source file with an accelerator for the word
+// named on the command line, in the package named after it, which
+// is `accel` if none is given.
+func genGo() {
+	if len(os.Args) != 3 && len(os.Args) != 4 {
+		fmt.Fprintf(os.Stderr, "abc: gen-go takes the name of a word and a package\n")
+		os.Exit(2)
+	}
+	word, pkg := os.Args[2], "accel"
+	if len(os.Args) == 4 {
+		pkg = os.Args[3]
+	}
+	body, err := abc.NewLoader(".").Load(word)
+	if err == nil {
+		var src []byte
+		src, err = abc.GenerateGo(pkg, word, body)
+		os.Stdout.Write(src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "abc: %s: %s\n", word, err)
+		os.Exit(1)
+	}
+}
+
+// bench measures each program in the benchmark corpus, which is the
+// directory named on the command line, or `bench` if none is given.
+// Rewriting is reported in steps per second and allocations per step.
+func bench() {
+	const defaultQuota = 10000000
+	dir := "bench"
+	if len(os.Args) > 2 {
+		dir = os.Args[2]
+	}
+	works, err := abc.ReadWorkloads(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+		os.Exit(1)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(out, "workload\toperation\tsteps\tns/op\tallocs/op\tsteps/s\tallocs/step\t\n")
+	for _, work := range works {
+		ops, err := work.Operations(defaultQuota, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+			os.Exit(1)
+		}
+		for _, op := range ops {
+			m := op.Measure(time.Second)
+			fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%d\t%.0f\t%.3f\t\n",
+				work.Name, op.Name, m.Steps, m.NsPerOp(), m.AllocsPerOp(),
+				m.StepsPerSec(), m.AllocsPerStep())
+		}
+	}
+	out.Flush()
+}
+
+// conform runs the conformance cases in the directory named on the
+// command line, or `conformance` if none is given, loading words from
+// its subdirectory `words`. Each failure is printed, and the exit
+// status is 1 if there are any.
+func conform() {
+	dir := "conformance"
+	if len(os.Args) > 2 {
+		dir = os.Args[2]
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		panic(err)
+	}
+	loader := abc.NewLoader(filepath.Join(dir, "words"))
+	total, failed := 0, 0
+	for _, path := range paths {
+		cases, err := abc.ReadCases(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+			os.Exit(1)
+		}
+		for _, test := range cases {
+			total++
+			err := test.Check(loader)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "abc: %s\n", err)
+				failed++
+			}
+		}
+	}
+	fmt.Printf("%d cases, %d failed\n", total, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func checkWord(name string, object abc.Object) error {
+	effect, err := abc.Infer(object)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s\n", name, effect)
+	return nil
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// run runs a command with the given input on stdin, returning what it
+// prints on stdout.
+func run(t *testing.T, command func(), input string) string {
+	t.Helper()
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "stdin"), filepath.Join(dir, "stdout")
+	err := ioutil.WriteFile(in, []byte(input), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	defer func(stdin, stdout *os.File) {
+		os.Stdin, os.Stdout = stdin, stdout
+	}(os.Stdin, os.Stdout)
+	os.Stdin, os.Stdout = stdin, stdout
+	command()
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got)
+}
+
+// `abc effect` prints what a program does to boxes of unknown code.
+func TestEffect(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"f", "[X] [Y] -> [Y] [X]\n"},
+		{"d c", "[X] -> [X X]\n"},
+		{"f a", "[X] [Y] -> [Y] X\n"},
+		{"[d a] f e", "[X] -> [d a]\n"},
+	}
+	for _, test := range cases {
+		got := run(t, effect, test.src)
+		if got != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+	}
+}
+
+// `abc fmt` rewrites a file only when its layout changes, keeping its
+// mode, and leaves a file it can't read as a program alone.
+func TestFormatFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		src  string
+		want string
+		err  bool
+	}{
+		{"a  b\n[c\n d]", "a b [c d]\n", false},
+		{"(note) \\x -> x x\n", "(note) \\x -> x x\n", false},
+		{"[a b", "[a b", true},
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, test := range cases {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		err := ioutil.WriteFile(path, []byte(test.src), 0600)
+		if err == nil {
+			err = os.Chtimes(path, old, old)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = formatFile(path)
+		if (err != nil) != test.err {
+			t.Errorf("`%s`: expected an error to be %v, but got %v", test.src, test.err, err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("`%s`: expected the file to hold `%s`, but it holds `%s`", test.src, test.want, got)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("`%s`: the file's mode changed to %v", test.src, info.Mode())
+		}
+		changed := test.src != test.want
+		written := !info.ModTime().Equal(old)
+		if written != changed {
+			t.Errorf("`%s`: expected the file to be rewritten only if its layout changes", test.src)
+		}
+	}
+}
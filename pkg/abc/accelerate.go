@@ -0,0 +1,82 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import ()
+
+// An Accelerator runs a word natively, in place of its definition.
+// It is given the values on the stack, which are boxes, with the top
+// last. It returns the new stack and the code left to run, or false
+// if it can't run on the values given, in which case the definition
+// is used after all, as it is if the new stack holds anything but
+// boxes. It must not modify the stack it is given.
+//
+// An accelerator counts as a single step against the quota, however
+// much work it does, so running out of quota may leave a program in
+// a different state than its definition would.
+type Accelerator func(stack []Object) ([]Object, Object, bool)
+
+// Accelerate registers an accelerator for a word. It must do what
+// the word's definition does; see GenerateGo.
+func (loader *Loader) Accelerate(name string, fn Accelerator) {
+	loader.lock.Lock()
+	defer loader.lock.Unlock()
+	loader.accel[name] = fn
+}
+
+// accelerator returns the accelerator of a word, or nil.
+func (loader *Loader) accelerator(name string) Accelerator {
+	loader.lock.Lock()
+	defer loader.lock.Unlock()
+	return loader.accel[name]
+}
+
+// run calls an accelerator, returning false if it can't run on the
+// stack, or returns something other than boxes, in which case the
+// definition is used instead. A nil rest is the empty program.
+func (fn Accelerator) run(stack []Object) ([]Object, Object, bool) {
+	out, rest, ok := fn(stack)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, object := range out {
+		_, ok = object.(*mkBox)
+		if !ok {
+			return nil, nil, false
+		}
+	}
+	return out, orEmpty(rest), true
+}
+
+// accelerate runs the accelerator of a word, returning false if it
+// has none or it can't run on the values on the stack.
+func (ctx *rewrite) accelerate(word mkVar) bool {
+	fn := ctx.loader.accelerator(word.name)
+	if fn == nil {
+		return false
+	}
+	stack, rest, ok := fn.run(ctx.data.data)
+	if !ok {
+		return false
+	}
+	ctx.data.data = stack
+	ctx.work.push(rest)
+	return true
+}
@@ -0,0 +1,202 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// declare checks a `(type ...)` annotation against the effect of the
+// code that follows it, up to the end of the enclosing box or word,
+// and returns the stack left by that code. The code must have the
+// declared effect or a more general one.
+func (ctx *infer) declare(note mkNote, stack row, rest Object) (row, error) {
+	top := ctx.frames[len(ctx.frames)-1]
+	top.term++
+	term := top.term
+	fail := func(reason string) error {
+		err := ctx.fail(note, reason).(*InferError)
+		err.Term = term
+		err.Pos = note.pos
+		return err
+	}
+	text := strings.TrimPrefix(note.text, "type ")
+	declared, err := ctx.parseEffect(text)
+	if err != nil {
+		return stack, fail(err.Error())
+	}
+	actual, err := ctx.program(rest)
+	if err != nil {
+		return stack, err
+	}
+	actual = ctx.zonkEffect(actual)
+	shown := actual.String()
+	want := ctx.instantiate(declared)
+	set := make(map[int]bool)
+	ctx.freeEffect(want, set)
+	for id := range set {
+		ctx.rigid[id] = true
+	}
+	err = ctx.unifyRow(actual.in, want.in)
+	if err == nil {
+		err = ctx.unifyRow(actual.out, want.out)
+	}
+	for id := range set {
+		delete(ctx.rigid, id)
+	}
+	if err != nil {
+		msg := "declared `%s`, but the code has effect `%s`"
+		return stack, fail(fmt.Sprintf(msg, declared.effect, shown))
+	}
+	effect := ctx.instantiate(declared)
+	err = ctx.unifyRow(stack, effect.in)
+	if err != nil {
+		return stack, fail(err.Error())
+	}
+	return effect.out, nil
+}
+
+// effectParser reads effects written the way Effect prints them.
+// Names are shared across the whole effect, except for the stack
+// left out of a box's effect, which belongs to that box.
+type effectParser struct {
+	ctx    *infer
+	tokens []string
+	names  map[string]int
+}
+
+var effectName = regexp.MustCompile("^[A-Z][A-Za-z0-9]*$")
+
+func (ctx *infer) parseEffect(text string) (*scheme, error) {
+	text = strings.Replace(text, "[", " [ ", -1)
+	text = strings.Replace(text, "]", " ] ", -1)
+	parser := &effectParser{
+		ctx:    ctx,
+		tokens: strings.Fields(text),
+		names:  make(map[string]int),
+	}
+	effect, err := parser.effect(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(parser.tokens) > 0 {
+		return nil, fmt.Errorf("unexpected `%s` in type", parser.tokens[0])
+	}
+	bound := make(map[int]bool)
+	ctx.freeEffect(effect, bound)
+	return &scheme{effect, bound, false}, nil
+}
+
+func (parser *effectParser) peek(n int) string {
+	if n < len(parser.tokens) {
+		return parser.tokens[n]
+	}
+	return ""
+}
+
+func (parser *effectParser) next() string {
+	token := parser.peek(0)
+	if len(parser.tokens) > 0 {
+		parser.tokens = parser.tokens[1:]
+	}
+	return token
+}
+
+func (parser *effectParser) name(name string) int {
+	id, ok := parser.names[name]
+	if !ok {
+		id = parser.ctx.fresh()
+		parser.names[name] = id
+	}
+	return id
+}
+
+// effect reads an effect. If bound is non-nil, the effect belongs to
+// a box, and a stack left out of the effect is bound by the box.
+func (parser *effectParser) effect(bound map[int]bool) (Effect, error) {
+	in, err := parser.row()
+	if err != nil {
+		return Effect{}, err
+	}
+	token := parser.next()
+	if token != "--" {
+		return Effect{}, fmt.Errorf("expected `--` in type, not `%s`", token)
+	}
+	out, err := parser.row()
+	if err != nil {
+		return Effect{}, err
+	}
+	switch {
+	case in.rest == 0 && out.rest == 0:
+		rest := parser.ctx.fresh()
+		if bound != nil {
+			bound[rest] = true
+		}
+		in.rest, out.rest = rest, rest
+	case in.rest == 0 || out.rest == 0:
+		msg := "both sides of an effect must name the rest of the stack"
+		return Effect{}, errors.New(msg)
+	}
+	return Effect{in, out}, nil
+}
+
+func (parser *effectParser) row() (row, error) {
+	var stack row
+	token := parser.peek(0)
+	if strings.HasPrefix(token, "..") {
+		parser.next()
+		name := token[2:]
+		if !effectName.MatchString(name) {
+			return stack, fmt.Errorf("`%s` is not a stack name", token)
+		}
+		stack.rest = parser.name(token)
+	}
+	for parser.peek(0) == "[" {
+		object, err := parser.term()
+		if err != nil {
+			return stack, err
+		}
+		stack = stack.push(object)
+	}
+	return stack, nil
+}
+
+func (parser *effectParser) term() (*term, error) {
+	parser.next()
+	name := parser.peek(0)
+	if effectName.MatchString(name) && parser.peek(1) == "]" {
+		parser.next()
+		parser.next()
+		return &term{id: parser.name(name)}, nil
+	}
+	bound := make(map[int]bool)
+	effect, err := parser.effect(bound)
+	if err != nil {
+		return nil, err
+	}
+	token := parser.next()
+	if token != "]" {
+		return nil, fmt.Errorf("expected `]` in type, not `%s`", token)
+	}
+	return &term{box: &scheme{effect, bound, false}}, nil
+}
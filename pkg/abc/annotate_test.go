@@ -0,0 +1,60 @@
+package abc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	defs := map[string]string{
+		"swap":    "(type [A] [B] -- [B] [A]) f\n",
+		"apply":   "(type ..S [..S -- ..T] -- ..T) a",
+		"compose": "(type [..S -- ..T] [..T -- ..U] -- [..S -- ..U]) c",
+		"general": "(type [A] -- [A] [A]) [d] a",
+		"inner":   "[(type [A] -- [A] [A]) d] a",
+	}
+	for name, src := range defs {
+		define(t, dir, name, src)
+	}
+	loader := NewLoader(dir)
+	for name := range defs {
+		_, err := loader.Infer(MustRead(name))
+		if err != nil {
+			t.Errorf("`%s`: %s", name, err)
+		}
+	}
+}
+
+// A mismatched annotation is reported where it was written, in the
+// file of the word that contains it.
+func TestAnnotationMismatch(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "swap", "(type [A] [B] -- [B] [A]) f")
+	define(t, dir, "bad-swap", "\n  (type [A] [B] -- [A] [B])\n f")
+	define(t, dir, "use", "swap bad-swap")
+	define(t, dir, "too-general", "(type [A] [B] -- [B] [A]) e e [] []")
+	loader := NewLoader(dir)
+	_, err := loader.Infer(MustRead("use"))
+	infer, ok := err.(*InferError)
+	if !ok {
+		t.Fatalf("expected an InferError, but got %v", err)
+	}
+	pos := Pos{filepath.Join(dir, "bad-swap"), 2, 3}
+	if infer.Pos != pos {
+		t.Errorf("expected the error at %s, but got %s", pos, infer.Pos)
+	}
+	if strings.Join(infer.Words, " ") != "use bad-swap" || infer.Term != 1 {
+		t.Errorf("expected term 1 in `use` and `bad-swap`, but got %s", err)
+	}
+	want := pos.String() + ": in `use`: in `bad-swap`: term 1 `(type [A] [B] -- [A] [B])`: " +
+		"declared `[A] [B] -- [A] [B]`, but the code has effect `[A] [B] -- [B] [A]`"
+	if err.Error() != want {
+		t.Errorf("expected %q, but got %q", want, err)
+	}
+	_, err = loader.Infer(MustRead("too-general"))
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "too-general")+":1:1: ") {
+		t.Errorf("expected an error at the start of `too-general`, but got %v", err)
+	}
+}
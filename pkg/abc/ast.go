@@ -0,0 +1,263 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A Kind is the sort of an object, for inspecting programs built by
+// Read or by the constructors below.
+type Kind int
+
+const (
+	// KindEmpty is the empty program, which does nothing.
+	KindEmpty Kind = iota
+	// KindCat is a sequence of objects; see Parts.
+	KindCat
+	// KindBox is a box; see Body.
+	KindBox
+	// KindApp, KindWrap, KindCompose, KindCopy, KindDrop and
+	// KindSwap are the primitives `a` to `f`.
+	KindApp
+	KindWrap
+	KindCompose
+	KindCopy
+	KindDrop
+	KindSwap
+	// KindWord is a word; see Name.
+	KindWord
+	// KindNote is an annotation; see Text.
+	KindNote
+	// KindLink is a link; see Hash.
+	KindLink
+	// KindHole is a placeholder for unknown code; see Hole.
+	KindHole
+)
+
+var kindNames = []string{
+	"empty", "cat", "box", "a", "b", "c", "d", "e", "f",
+	"word", "note", "link", "hole",
+}
+
+func (kind Kind) String() string {
+	if kind < 0 || int(kind) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(kind))
+	}
+	return kindNames[kind]
+}
+
+// KindOf returns the sort of an object.
+func KindOf(object Object) Kind {
+	switch object.(type) {
+	case *mkCat:
+		return KindCat
+	case *mkBox:
+		return KindBox
+	case opApp:
+		return KindApp
+	case opBox:
+		return KindWrap
+	case opCat:
+		return KindCompose
+	case opCopy:
+		return KindCopy
+	case opDrop:
+		return KindDrop
+	case opSwap:
+		return KindSwap
+	case mkVar:
+		return KindWord
+	case mkNote:
+		return KindNote
+	case mkLink:
+		return KindLink
+	case mkHole:
+		return KindHole
+	default:
+		return KindEmpty
+	}
+}
+
+// Empty returns the empty program.
+func Empty() Object { return opId{} }
+
+// App returns the primitive `a`, which applies a box.
+func App() Object { return opApp{} }
+
+// Wrap returns the primitive `b`, which puts a box in a box.
+func Wrap() Object { return opBox{} }
+
+// Compose returns the primitive `c`, which concatenates two boxes.
+func Compose() Object { return opCat{} }
+
+// Copy returns the primitive `d`, which copies a box.
+func Copy() Object { return opCopy{} }
+
+// Drop returns the primitive `e`, which drops a box.
+func Drop() Object { return opDrop{} }
+
+// Swap returns the primitive `f`, which swaps two boxes.
+func Swap() Object { return opSwap{} }
+
+// Box returns a box with the given body.
+func Box(body Object) Object { return newBox(orEmpty(body)) }
+
+// Cat returns the program that runs each object in turn. Nil objects
+// are dropped, as if they were the empty program.
+func Cat(objects ...Object) Object {
+	var code []Object
+	for _, object := range objects {
+		if object != nil {
+			code = append(code, object)
+		}
+	}
+	return newCats(code...)
+}
+
+// orEmpty returns the empty program in place of a nil object, which
+// is what the functions of this package take nil to mean.
+func orEmpty(object Object) Object {
+	if object == nil {
+		return opId{}
+	}
+	return object
+}
+
+var wordName = regexp.MustCompile("^[a-z][a-z0-9-]+$")
+
+// Word returns a word, which is resolved by a Loader when it is
+// rewritten. Its name must be one that Read would accept.
+func Word(name string) (Object, error) {
+	if !wordName.MatchString(name) {
+		return nil, fmt.Errorf("`%s` is not a word", name)
+	}
+	return newVar(name, nil), nil
+}
+
+// Note returns an annotation, which does nothing when it is run.
+func Note(text string) Object { return newNote(text, Pos{}) }
+
+// Link returns a link to the object with the given hash.
+func Link(hash []byte) Object {
+	return mkLink{append([]byte(nil), hash...)}
+}
+
+// Parts returns the objects that a program runs in turn. It is empty
+// for the empty program or nil, and holds just the object itself for
+// anything but a sequence.
+func Parts(object Object) []Object { return flat(orEmpty(object)) }
+
+// Body returns the body of a box, or nil for other objects.
+func Body(object Object) Object {
+	box, ok := object.(*mkBox)
+	if !ok {
+		return nil
+	}
+	return box.body
+}
+
+// Name returns the name of a word or placeholder, or "" for other
+// objects.
+func Name(object Object) string {
+	switch object := object.(type) {
+	case mkVar:
+		return object.name
+	case mkHole:
+		return object.name
+	default:
+		return ""
+	}
+}
+
+// Text returns the text of an annotation, or "" for other objects.
+func Text(object Object) string {
+	note, ok := object.(mkNote)
+	if !ok {
+		return ""
+	}
+	return note.text
+}
+
+// Hash returns the hash of a link, or nil for other objects.
+func Hash(object Object) []byte {
+	link, ok := object.(mkLink)
+	if !ok {
+		return nil
+	}
+	return append([]byte(nil), link.value...)
+}
+
+// Walk calls fn on an object and everything inside it, including the
+// bodies of boxes, in reading order, until fn returns false.
+func Walk(object Object, fn func(Object) bool) {
+	todo := []Object{object}
+	for len(todo) > 0 {
+		object := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if !fn(object) {
+			return
+		}
+		switch object := object.(type) {
+		case *mkCat:
+			todo = append(todo, object.snd, object.fst)
+		case *mkBox:
+			todo = append(todo, object.body)
+		}
+	}
+}
+
+// Transform rebuilds an object from the bottom up, replacing each
+// part of it with the result of fn. Boxes are given to fn after their
+// bodies have been transformed, and sequences are rebuilt from their
+// transformed parts rather than given to fn. Returning nil or the
+// empty program removes a part, just as Cat drops nil objects, and
+// returning a sequence splices its parts in place.
+func Transform(object Object, fn func(Object) Object) Object {
+	type frame struct {
+		box   *mkBox
+		parts []Object
+		done  []Object
+	}
+	top := &frame{parts: flat(orEmpty(object))}
+	stack := []*frame{top}
+	for {
+		next := stack[len(stack)-1]
+		if len(next.done) < len(next.parts) {
+			part := next.parts[len(next.done)]
+			box, ok := part.(*mkBox)
+			if ok {
+				stack = append(stack, &frame{box: box, parts: flat(box.body)})
+			} else {
+				next.done = append(next.done, fn(part))
+			}
+			continue
+		}
+		body := Cat(next.done...)
+		if next.box == nil {
+			return body
+		}
+		stack = stack[:len(stack)-1]
+		outer := stack[len(stack)-1]
+		box := fn(&mkBox{body, next.box.src})
+		outer.done = append(outer.done, box)
+	}
+}
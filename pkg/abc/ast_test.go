@@ -0,0 +1,191 @@
+package abc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKinds(t *testing.T) {
+	word, err := Word("swap-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		object Object
+		kind   Kind
+		src    string
+	}{
+		{Empty(), KindEmpty, ""},
+		{App(), KindApp, "a"},
+		{Wrap(), KindWrap, "b"},
+		{Compose(), KindCompose, "c"},
+		{Copy(), KindCopy, "d"},
+		{Drop(), KindDrop, "e"},
+		{Swap(), KindSwap, "f"},
+		{Box(nil), KindBox, "[]"},
+		{Box(Swap()), KindBox, "[f]"},
+		{word, KindWord, "swap-apply"},
+		{Note("see swap"), KindNote, "(see swap)"},
+		{Cat(Copy(), Drop()), KindCat, "d e"},
+	}
+	for _, test := range cases {
+		if KindOf(test.object) != test.kind {
+			t.Errorf("`%s`: expected %s, but got %s", test.src, test.kind, KindOf(test.object))
+		}
+		if !Equals(test.object, MustRead(test.src)) {
+			t.Errorf("expected `%s`, but got `%s`", test.src, test.object)
+		}
+	}
+	if KindOf(nil) != KindEmpty {
+		t.Errorf("nil: expected empty, but got %s", KindOf(nil))
+	}
+	if Kind(99).String() != "Kind(99)" {
+		t.Errorf("expected Kind(99), but got %s", Kind(99))
+	}
+	for _, name := range []string{"Swap", "swap!", "x", ""} {
+		_, err := Word(name)
+		if err == nil {
+			t.Errorf("`%s`: expected an error", name)
+		}
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	object := MustRead("[d f] (hi) swap-apply e")
+	parts := Parts(object)
+	if len(parts) != 4 {
+		t.Fatalf("expected 4 parts, but got %d", len(parts))
+	}
+	if body := Body(parts[0]); !Equals(body, MustRead("d f")) {
+		t.Errorf("expected the body `d f`, but got `%s`", body)
+	}
+	if Body(parts[3]) != nil {
+		t.Errorf("expected no body for `e`")
+	}
+	if Text(parts[1]) != "hi" || Text(parts[2]) != "" {
+		t.Errorf("expected the text `hi`, but got `%s`", Text(parts[1]))
+	}
+	if Name(parts[2]) != "swap-apply" || Name(parts[3]) != "" {
+		t.Errorf("expected the name `swap-apply`, but got `%s`", Name(parts[2]))
+	}
+	if len(Parts(Empty())) != 0 || len(Parts(nil)) != 0 {
+		t.Errorf("expected no parts in the empty program")
+	}
+	if len(Parts(Swap())) != 1 {
+		t.Errorf("expected `f` to be its only part")
+	}
+	hash := []byte{1, 2, 3}
+	link := Link(hash)
+	hash[0] = 9
+	got := Hash(link)
+	if !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Fatalf("expected the hash 010203, but got %x", got)
+	}
+	got[0] = 9
+	if !bytes.Equal(Hash(link), []byte{1, 2, 3}) {
+		t.Errorf("a link's hash was changed through Hash")
+	}
+	if Hash(Swap()) != nil {
+		t.Errorf("expected no hash for `f`")
+	}
+}
+
+func TestCat(t *testing.T) {
+	object := Cat(nil, Copy(), Empty(), nil, Cat(Swap(), Drop()))
+	if !Equals(object, MustRead("d f e")) {
+		t.Errorf("expected `d f e`, but got `%s`", object)
+	}
+	if len(Parts(object)) != 3 {
+		t.Errorf("expected the sequences to be spliced, but got %d parts", len(Parts(object)))
+	}
+	if KindOf(Cat()) != KindEmpty || KindOf(Cat(nil)) != KindEmpty {
+		t.Errorf("expected the empty program from no objects")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var seen []string
+	Walk(MustRead("[d [e]] f"), func(object Object) bool {
+		if KindOf(object) != KindCat {
+			seen = append(seen, object.String())
+		}
+		return true
+	})
+	want := "[d [e]],d,[e],e,f"
+	if got := strings.Join(seen, ","); got != want {
+		t.Errorf("expected %s, but got %s", want, got)
+	}
+	count := 0
+	Walk(MustRead("[d] e f"), func(object Object) bool {
+		count++
+		return KindOf(object) != KindBox
+	})
+	// The sequence is visited before the box that starts it.
+	if count != 2 {
+		t.Errorf("expected Walk to stop at the box, after 2 calls, but it made %d", count)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   func(Object) Object
+		src  string
+		want string
+	}{
+		{
+			"nil deletes",
+			func(object Object) Object {
+				if KindOf(object) == KindDrop {
+					return nil
+				}
+				return object
+			},
+			"e d [e] [[e] e] e", "d [] [[]]",
+		},
+		{
+			"empty deletes",
+			func(object Object) Object {
+				if KindOf(object) == KindDrop {
+					return Empty()
+				}
+				return object
+			},
+			"e d [e] [[e] e] e", "d [] [[]]",
+		},
+		{
+			"sequences splice",
+			func(object Object) Object {
+				if KindOf(object) == KindSwap {
+					return Cat(Copy(), Drop())
+				}
+				return object
+			},
+			"[f] f", "[d e] d e",
+		},
+		{
+			"boxes come after their bodies",
+			func(object Object) Object {
+				if KindOf(object) == KindBox && KindOf(Body(object)) == KindEmpty {
+					return nil
+				}
+				if KindOf(object) == KindDrop {
+					return nil
+				}
+				return object
+			},
+			"[[e]] [d e] a", "[d] a",
+		},
+	}
+	for _, test := range cases {
+		got := Transform(MustRead(test.src), test.fn)
+		if !Equals(got, MustRead(test.want)) {
+			t.Errorf("%s: `%s` became `%s`, but expected `%s`", test.name, test.src, got, test.want)
+		}
+	}
+	got := Transform(nil, func(object Object) Object { return object })
+	if KindOf(got) != KindEmpty {
+		t.Errorf("expected nil to transform to the empty program, but got `%s`", got)
+	}
+}
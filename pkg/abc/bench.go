@@ -0,0 +1,183 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Workload is a program to measure, read from a file in a
+// benchmark corpus. The program must finish within the quota it is
+// measured with.
+type Workload struct {
+	Name   string
+	Source string
+	Code   Object
+}
+
+// ReadWorkloads reads every file in a directory as a workload, named
+// after the file, in order of name.
+func ReadWorkloads(dir string) ([]Workload, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var works []Workload
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		code, err := read(strings.NewReader(string(buf)), path)
+		if err != nil {
+			return nil, err
+		}
+		works = append(works, Workload{info.Name(), string(buf), code})
+	}
+	sort.Slice(works, func(i, j int) bool {
+		return works[i].Name < works[j].Name
+	})
+	return works, nil
+}
+
+// An Operation is something to measure on a workload. Steps is the
+// number of rewriting steps that each run of it takes, or zero if it
+// doesn't rewrite.
+type Operation struct {
+	Name  string
+	Steps int64
+	Run   func()
+}
+
+// Operations returns the operations measured on a workload: rewriting
+// it with Rewrite and with a compiled Program, reading its source, and
+// printing and comparing its result. Words are loaded with loader,
+// which may be nil. It returns an error if the workload doesn't
+// finish within the quota.
+func (work Workload) Operations(quota int, loader *Loader) ([]Operation, error) {
+	if loader == nil {
+		loader = defaultLoader
+	}
+	left := int64(quota)
+	result := reduce(work.Code, &left, &options{loader: loader})
+	if left < 0 {
+		msg := "`%s` doesn't finish within %d steps"
+		return nil, fmt.Errorf(msg, work.Name, quota)
+	}
+	steps := int64(quota) - left
+	config := Config{Quota: quota, Loader: loader}
+	prog := Compile(work.Code)
+	text := result.String()
+	other, err := Read(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return []Operation{
+		{"rewrite", steps, func() { RewriteWith(work.Code, config) }},
+		{"vm", steps, func() { prog.RewriteWith(config) }},
+		{"read", 0, func() { Read(strings.NewReader(work.Source)) }},
+		{"string", 0, func() { _ = result.String() }},
+		{"equals", 0, func() { Equals(result, other) }},
+	}, nil
+}
+
+// A Measurement is the result of running an operation repeatedly.
+type Measurement struct {
+	Runs    int
+	Elapsed time.Duration
+	Allocs  uint64
+	Bytes   uint64
+	Steps   int64
+}
+
+// Measure runs an operation, after a first run to warm up, as many
+// times as it takes to spend at least the given duration, doubling the
+// number of runs between checks of the clock. Allocations are counted
+// from the runtime's memory statistics.
+func (op Operation) Measure(duration time.Duration) Measurement {
+	op.Run()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	runs := 0
+	for n := 1; ; n *= 2 {
+		for i := 0; i < n; i++ {
+			op.Run()
+		}
+		runs += n
+		if time.Since(start) >= duration {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return Measurement{
+		Runs:    runs,
+		Elapsed: elapsed,
+		Allocs:  after.Mallocs - before.Mallocs,
+		Bytes:   after.TotalAlloc - before.TotalAlloc,
+		Steps:   op.Steps,
+	}
+}
+
+// NsPerOp returns the time taken by each run.
+func (m Measurement) NsPerOp() int64 {
+	if m.Runs == 0 {
+		return 0
+	}
+	return m.Elapsed.Nanoseconds() / int64(m.Runs)
+}
+
+// AllocsPerOp returns the allocations made by each run.
+func (m Measurement) AllocsPerOp() int64 {
+	if m.Runs == 0 {
+		return 0
+	}
+	return int64(m.Allocs) / int64(m.Runs)
+}
+
+// StepsPerSec returns the rate of rewriting, or zero for an operation
+// that doesn't rewrite.
+func (m Measurement) StepsPerSec() float64 {
+	if m.Steps == 0 || m.Elapsed <= 0 {
+		return 0
+	}
+	return float64(m.Steps) * float64(m.Runs) / m.Elapsed.Seconds()
+}
+
+// AllocsPerStep returns the allocations made by each rewriting step,
+// or zero for an operation that doesn't rewrite.
+func (m Measurement) AllocsPerStep() float64 {
+	if m.Steps == 0 || m.Runs == 0 {
+		return 0
+	}
+	return float64(m.Allocs) / float64(m.Runs) / float64(m.Steps)
+}
@@ -0,0 +1,79 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchQuota is enough for every program in the benchmark corpus.
+const benchQuota = 10000000
+
+func readCorpus(tb testing.TB) []Workload {
+	works, err := ReadWorkloads("../../bench")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return works
+}
+
+func BenchmarkCorpus(b *testing.B) {
+	for _, work := range readCorpus(b) {
+		ops, err := work.Operations(benchQuota, noWords)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, op := range ops {
+			op := op
+			b.Run(work.Name+"/"+op.Name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					op.Run()
+				}
+				if op.Steps > 0 {
+					b.ReportMetric(float64(op.Steps), "steps/op")
+				}
+			})
+		}
+	}
+}
+
+// The church workload adds two and three, multiplies the sum by three
+// and then four, and applies the result, sixty, to `b` on `[]`.
+func TestChurch(t *testing.T) {
+	for _, work := range readCorpus(t) {
+		if work.Name != "church" {
+			continue
+		}
+		got := RewriteWith(work.Code, Config{Quota: benchQuota, Loader: noWords})
+		want := strings.Repeat("[", 61) + strings.Repeat("]", 61)
+		if got.String() != want {
+			t.Fatalf("expected sixty boxes around `[]`, but got `%s`", got)
+		}
+		return
+	}
+	t.Fatal("there is no church workload")
+}
+
+func TestMeasure(t *testing.T) {
+	work := Workload{Name: "swap", Source: "[d] [e] f", Code: MustRead("[d] [e] f")}
+	ops, err := work.Operations(100, noWords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range ops {
+		m := op.Measure(time.Millisecond)
+		if m.Runs == 0 || m.Elapsed < time.Millisecond || m.NsPerOp() <= 0 {
+			t.Errorf("%s: expected at least a millisecond of runs, but got %+v", op.Name, m)
+		}
+		rewrites := op.Name == "rewrite" || op.Name == "vm"
+		if rewrites != (m.Steps == 1) || rewrites != (m.StepsPerSec() > 0) {
+			t.Errorf("%s: expected one step if it rewrites, but got %d", op.Name, m.Steps)
+		}
+	}
+	loop := Workload{Name: "loop", Code: MustRead("[d a] d a")}
+	_, err = loop.Operations(100, noWords)
+	if err == nil || !strings.Contains(err.Error(), "doesn't finish") {
+		t.Errorf("expected an endless workload to fail, but got %v", err)
+	}
+}
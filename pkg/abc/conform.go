@@ -0,0 +1,165 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A Case is a conformance test: a program, the quota it is rewritten
+// with, the result, and the diagnostics for the code that got stuck,
+// in order. A diagnostic ending in `...` only has to match up to it.
+type Case struct {
+	Pos     Pos
+	Program string
+	Quota   int
+	Result  string
+	Stuck   []string
+}
+
+// defaultCaseQuota is the quota of a case that doesn't give one.
+const defaultCaseQuota = 1000
+
+// ReadCases reads the conformance cases in a file. Each case is a
+// group of lines separated from the next by a blank line:
+//
+//	# `f` swaps the top two values.
+//	program: [d] [e] f
+//	quota: 10
+//	result: [e] [d]
+//
+// Lines starting with `#` are comments. `quota` may be left out, and
+// `stuck` may be given any number of times.
+func ReadCases(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var cases []Case
+	var next *Case
+	lines := bufio.NewScanner(file)
+	pos := Pos{path, 0, 1}
+	for lines.Scan() {
+		pos.Line++
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			next = nil
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		split := strings.Index(line, ":")
+		if split < 0 {
+			return nil, fmt.Errorf("%s: Expected `key: value`", pos)
+		}
+		key := line[:split]
+		value := strings.TrimSpace(line[split+1:])
+		if next == nil {
+			cases = append(cases, Case{Pos: pos, Quota: defaultCaseQuota})
+			next = &cases[len(cases)-1]
+		}
+		switch key {
+		case "program":
+			next.Program = value
+		case "quota":
+			next.Quota, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: `%s` is not a quota", pos, value)
+			}
+		case "result":
+			next.Result = value
+		case "stuck":
+			next.Stuck = append(next.Stuck, value)
+		default:
+			return nil, fmt.Errorf("%s: Unknown key `%s`", pos, key)
+		}
+	}
+	err = lines.Err()
+	if err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// Check rewrites the program of a case, both with Rewrite and as a
+// compiled Program, returning an error that describes the first
+// difference from what was expected. Words are loaded with loader,
+// which may be nil.
+func (test Case) Check(loader *Loader) error {
+	program, err := Read(strings.NewReader(test.Program))
+	if err != nil {
+		return fmt.Errorf("%s: %s", test.Pos, err)
+	}
+	result, err := Read(strings.NewReader(test.Result))
+	if err != nil {
+		return fmt.Errorf("%s: %s", test.Pos, err)
+	}
+	runs := []struct {
+		name string
+		run  func(Config) Object
+	}{
+		{"rewrite", func(config Config) Object {
+			return RewriteWith(program, config)
+		}},
+		{"vm", Compile(program).RewriteWith},
+	}
+	for _, run := range runs {
+		var stuck []string
+		config := Config{Quota: test.Quota, Loader: loader}
+		config.Stuck = func(report Stuck) {
+			report.Origin = nil
+			stuck = append(stuck, report.String())
+		}
+		object := run.run(config)
+		if !Equals(object, result) {
+			msg := "%s: %s: expected `%s`, but got `%s`"
+			return fmt.Errorf(msg, test.Pos, run.name, result, object)
+		}
+		for i := 0; i < len(stuck) || i < len(test.Stuck); i++ {
+			switch {
+			case i == len(stuck):
+				msg := "%s: %s: expected stuck %q, but got nothing"
+				return fmt.Errorf(msg, test.Pos, run.name, test.Stuck[i])
+			case i == len(test.Stuck):
+				msg := "%s: %s: unexpected stuck %q"
+				return fmt.Errorf(msg, test.Pos, run.name, stuck[i])
+			case !matches(test.Stuck[i], stuck[i]):
+				msg := "%s: %s: expected stuck %q, but got %q"
+				return fmt.Errorf(msg, test.Pos, run.name, test.Stuck[i], stuck[i])
+			}
+		}
+	}
+	return nil
+}
+
+// matches predicates a diagnostic that was expected, allowing a
+// trailing `...` to stand for the rest of it.
+func matches(want, got string) bool {
+	if strings.HasSuffix(want, "...") {
+		return strings.HasPrefix(got, strings.TrimSuffix(want, "..."))
+	}
+	return want == got
+}
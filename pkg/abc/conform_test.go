@@ -0,0 +1,44 @@
+package abc
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// conformance is the directory of the conformance cases.
+var conformance = filepath.Join("..", "..", "conformance")
+
+// readCases reads every conformance case.
+func readCases(tb testing.TB) []Case {
+	paths, err := filepath.Glob(filepath.Join(conformance, "*.txt"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(paths) == 0 {
+		tb.Fatalf("no conformance cases in %s", conformance)
+	}
+	var all []Case
+	for _, path := range paths {
+		cases, err := ReadCases(path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		all = append(all, cases...)
+	}
+	return all
+}
+
+// Every conformance case holds for Rewrite and for compiled programs.
+func TestConformance(t *testing.T) {
+	loader := NewLoader(filepath.Join(conformance, "words"))
+	for _, test := range readCases(t) {
+		name := fmt.Sprintf("%s:%d", filepath.Base(test.Pos.File), test.Pos.Line)
+		t.Run(name, func(t *testing.T) {
+			err := test.Check(loader)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
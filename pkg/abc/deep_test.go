@@ -0,0 +1,81 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+// depth is the depth of the nested boxes in these tests, which would
+// overflow the stack of anything that recursed once per box.
+const depth = 1000000
+
+func nested(body string) string {
+	return strings.Repeat("[", depth) + body + strings.Repeat("]", depth)
+}
+
+func TestDeepRead(t *testing.T) {
+	src := nested("")
+	object, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.String() != src {
+		t.Fatal("the nested boxes aren't printed as they were read")
+	}
+	if Format(object, Options{}) != src {
+		t.Fatal("the nested boxes aren't formatted as they were read")
+	}
+	if !Equals(object, MustRead(src)) {
+		t.Fatal("the nested boxes aren't equal to themselves")
+	}
+	if Equals(object, MustRead(nested("a"))) {
+		t.Fatal("the nested boxes are equal to different boxes")
+	}
+}
+
+func TestDeepLong(t *testing.T) {
+	src := strings.Repeat("[] ", depth)
+	object := MustRead(src)
+	if object.String()+" " != src || !Equals(object, object) {
+		t.Fatal("a long program isn't printed as it was read")
+	}
+	if len(Parts(Cat(object, object))) != 2*depth {
+		t.Fatal("a long program isn't concatenated")
+	}
+}
+
+func TestDeepRewrite(t *testing.T) {
+	object := MustRead(nested("") + " d e " + nested("[e] [d] f"))
+	for _, strategy := range []Strategy{Eager, Lazy, Deep, Parallel} {
+		// Reaching the body of each box takes a step.
+		config := Config{Quota: 3 * depth, Strategy: strategy, Loader: noWords}
+		got := RewriteWith(object, config)
+		want := nested("")
+		if strategy != Eager {
+			want += " " + nested("[d] [e]")
+		} else {
+			want += " " + nested("[e] [d] f")
+		}
+		if got.String() != want {
+			t.Fatalf("strategy %d: the nested boxes aren't rewritten", strategy)
+		}
+	}
+}
+
+// Tracing copies the definition of a word, along with every box in it.
+func TestDeepTrace(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "nest", nested("f"))
+	config := Config{Quota: 10, Loader: NewLoader(dir), Trace: true}
+	got := RewriteWith(MustRead("nest"), config)
+	if got.String() != nested("f") {
+		t.Fatal("the definition isn't copied")
+	}
+	for i := 0; i < depth; i++ {
+		got = Body(got)
+	}
+	src := OriginOf(got)
+	if src == nil || strings.Join(src.Words, " ") != "nest" {
+		t.Fatalf("the innermost `f` has origin %v", src)
+	}
+}
@@ -0,0 +1,136 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+)
+
+// An Equivalence decides whether two programs behave the same, by
+// rewriting them and comparing the results. It can only prove that
+// programs are equivalent: when it reports false, the programs may
+// differ, or may just take too long to rewrite.
+type Equivalence struct {
+	// Quota bounds the effort spent rewriting both programs.
+	Quota int
+	// Store resolves the links in both programs, if it is not nil.
+	Store *Store
+	// Loader resolves words. If it is nil, words are read from files
+	// in the current directory.
+	Loader *Loader
+	// Depth, if it is positive, compares programs symbolically:
+	// each is run on a stack of Depth fresh variables, and the boxes
+	// in the results are compared the same way. Programs that only
+	// differ when there are fewer values on the stack, like `d e`
+	// and the empty program, are then equivalent.
+	Depth int
+}
+
+// Equivalent predicates programs that rewrite to the same result,
+// including inside boxes, within the quota.
+func Equivalent(fst, snd Object, quota int) bool {
+	return Equivalence{Quota: quota}.Equivalent(fst, snd)
+}
+
+// Equivalent predicates programs that rewrite to the same result.
+func (eqv Equivalence) Equivalent(fst, snd Object) bool {
+	loader := eqv.Loader
+	if loader == nil {
+		loader = defaultLoader
+	}
+	quota := int64(eqv.Quota)
+	ctx := &equivalence{
+		Equivalence: eqv,
+		deep:        &deep{quota: &quota, opts: &options{loader: loader}},
+		vars:        make(map[*mkBox]bool),
+	}
+	if eqv.Store != nil {
+		fst = eqv.Store.Resolve(fst)
+		snd = eqv.Store.Resolve(snd)
+	}
+	if eqv.Depth > 0 {
+		return ctx.symbolic(fst, snd)
+	}
+	lhs, ok := ctx.normalize(fst)
+	if !ok {
+		return false
+	}
+	rhs, ok := ctx.normalize(snd)
+	return ok && Equals(lhs, rhs)
+}
+
+type equivalence struct {
+	Equivalence
+	deep *deep
+	vars map[*mkBox]bool
+}
+
+// normalize rewrites an object under boxes, returning false if the
+// quota runs out first.
+func (ctx *equivalence) normalize(object Object) (Object, bool) {
+	object = ctx.deep.rewrite(object, ctx.deep.opts)
+	return object, *ctx.deep.quota >= 0
+}
+
+// variable returns a box that stands for an unknown value. Its body is
+// a link that no program refers to, so it gets stuck when applied.
+func (ctx *equivalence) variable() Object {
+	name := fmt.Sprintf("variable %d", len(ctx.vars))
+	box := &mkBox{mkLink{[]byte(name)}, nil}
+	ctx.vars[box] = true
+	return box
+}
+
+// symbolic compares programs by running them on the same stack of
+// fresh variables, and then comparing their results part by part.
+// Pairs of boxes are compared by running their bodies on new
+// variables, in turn, except for the variables themselves.
+func (ctx *equivalence) symbolic(fst, snd Object) bool {
+	todo := [][2]Object{{fst, snd}}
+	for len(todo) > 0 {
+		pair := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		stack := make([]Object, ctx.Depth)
+		for i := range stack {
+			stack[i] = ctx.variable()
+		}
+		quota, opts := ctx.deep.quota, ctx.deep.opts
+		lhs := reduce(newCats(append(stack, pair[0])...), quota, opts)
+		rhs := reduce(newCats(append(stack, pair[1])...), quota, opts)
+		if *quota < 0 {
+			return false
+		}
+		lhsParts, rhsParts := flat(lhs), flat(rhs)
+		if len(lhsParts) != len(rhsParts) {
+			return false
+		}
+		for i := range lhsParts {
+			lhsBox, ok := lhsParts[i].(*mkBox)
+			rhsBox, isBox := rhsParts[i].(*mkBox)
+			switch {
+			case ok && isBox && !ctx.vars[lhsBox] && !ctx.vars[rhsBox]:
+				todo = append(todo, [2]Object{lhsBox.body, rhsBox.body})
+			case ok != isBox || !Equals(lhsParts[i], rhsParts[i]):
+				return false
+			}
+		}
+	}
+	return true
+}
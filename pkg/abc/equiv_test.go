@@ -0,0 +1,76 @@
+package abc
+
+import (
+	"testing"
+)
+
+func TestEquivalent(t *testing.T) {
+	cases := []struct {
+		fst, snd string
+		depth    int
+		want     bool
+	}{
+		// Equivalent programs.
+		{"[d] [e] f", "[e] [d]", 0, true},
+		{"[d] [e] c", "[d e]", 0, true},
+		{"[[d] [e] f] b", "[[[e] [d]]]", 0, true},
+		{"[[] a] d c", "[]", 0, true},
+		{"[f] a", "f", 2, true},
+		{"d e", "", 1, true},
+		{"f f", "", 2, true},
+		{"[f]", "[f f f]", 2, true},
+		{"b a", "", 1, true},
+		// Programs that differ.
+		{"[d]", "[e]", 0, false},
+		{"[d] [e]", "[e] [d]", 0, false},
+		{"f", "", 2, false},
+		{"d", "", 1, false},
+		{"[f]", "[]", 2, false},
+		{"[f] [e] c", "[e f]", 1, false},
+		// Without a stack of variables, code waiting for its inputs is
+		// only equivalent to the same code.
+		{"d e", "", 0, false},
+		{"f f", "", 0, false},
+		{"f f", "f f", 0, true},
+		// Code that gets stuck is equivalent to code that gets stuck
+		// in the same way.
+		{"a", "a", 1, true},
+		{"a", "[] f a", 1, false},
+		{"a", "e", 1, false},
+		{"f", "f", 1, true},
+		{"f", "[] e f", 1, true},
+		{"missing", "missing", 0, true},
+		{"missing", "[] e missing", 2, true},
+		{"missing", "other", 0, false},
+		// Programs that don't finish within the quota are never
+		// equivalent, even to themselves.
+		{"[d a] d a", "[d a] d a", 0, false},
+		{"[d a] d a", "[d a] d a", 1, false},
+		{"[[d a] d a]", "[[d a] d a]", 0, false},
+		{"[[d a] d a]", "[[d a] d a]", 1, false},
+	}
+	for _, test := range cases {
+		eqv := Equivalence{Quota: 1000, Loader: noWords, Depth: test.depth}
+		got := eqv.Equivalent(MustRead(test.fst), MustRead(test.snd))
+		if got != test.want {
+			t.Errorf("`%s` and `%s` at depth %d: expected %v, but got %v",
+				test.fst, test.snd, test.depth, test.want, got)
+		}
+	}
+}
+
+func TestEquivalentLinks(t *testing.T) {
+	store := NewStore()
+	link := store.Put(MustRead("[e] a"))
+	linked := Cat(MustRead("[d] [f]"), link)
+	for _, depth := range []int{0, 2} {
+		eqv := Equivalence{Quota: 1000, Loader: noWords, Depth: depth}
+		if eqv.Equivalent(linked, MustRead("[d]")) {
+			t.Errorf("depth %d: a link is equivalent to its code without a store", depth)
+		}
+		eqv.Store = store
+		if !eqv.Equivalent(linked, MustRead("[d]")) {
+			t.Errorf("depth %d: `%s` isn't equivalent to `[d]` in the store", depth, linked)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options control how Format lays out a program.
+type Options struct {
+	// Width is the length that lines should not exceed. Lines are
+	// only broken between terms, so a single long word may still
+	// exceed it. If it is zero, the program is written on one line.
+	Width int
+	// Indent is the number of spaces by which the body of a box is
+	// indented, when it doesn't fit on one line.
+	Indent int
+	// Depth, if it is positive, is the number of boxes that may be
+	// nested before their bodies are elided and written `[...]`.
+	Depth int
+}
+
+// Format writes a program on lines no wider than the options allow.
+// Terms are filled onto each line, and a box that doesn't fit on a
+// line of its own is broken open, with its body indented on the lines
+// between its brackets:
+//
+//	a b [
+//	  c d
+//	] e
+//
+// Without elision, the result reads back as the same program.
+func Format(object Object, options Options) string {
+	root := layout(object, options.Depth)
+	ctx := &printer{options: options}
+	ctx.seq(root.body, 0)
+	return ctx.buf.String()
+}
+
+// FormatSource lays out the text of a program the same way Format
+// does, keeping its annotations and local variables as written. Its
+// result ends with a newline, and formatting it again changes
+// nothing. Depth is ignored, since eliding would lose code.
+func FormatSource(src string, options Options) (string, error) {
+	tokens, err := scan(src, "")
+	if err != nil {
+		return "", err
+	}
+	root := &doc{}
+	stack := []*doc{root}
+	var opens []Pos
+	for _, token := range tokens {
+		top := stack[len(stack)-1]
+		switch token.text {
+		case "[":
+			box := &doc{box: true}
+			top.body = append(top.body, box)
+			stack = append(stack, box)
+			opens = append(opens, token.pos)
+		case "]":
+			if len(stack) == 1 {
+				msg := "%s: Unbalanced block"
+				return "", fmt.Errorf(msg, token.pos)
+			}
+			stack = stack[:len(stack)-1]
+			opens = opens[:len(opens)-1]
+		default:
+			text := token.text
+			if text[0] == '(' {
+				text = newNote(text[1:len(text)-1], token.pos).String()
+			}
+			top.body = append(top.body, &doc{text: text})
+		}
+	}
+	if len(opens) > 0 {
+		msg := "%s: Unbalanced block"
+		return "", fmt.Errorf(msg, opens[len(opens)-1])
+	}
+	measure(root)
+	ctx := &printer{options: options}
+	ctx.seq(root.body, 0)
+	return ctx.buf.String() + "\n", nil
+}
+
+// A doc is a term being laid out: either some text, or a box of
+// other terms. Its width is the length of the term on one line.
+type doc struct {
+	text  string
+	box   bool
+	body  []*doc
+	width int
+}
+
+// layout builds the docs of a program, eliding boxes nested more
+// than depth deep when depth is positive.
+func layout(object Object, depth int) *doc {
+	root := &doc{}
+	type task struct {
+		into   *doc
+		object Object
+		depth  int
+	}
+	todo := []task{{root, object, 0}}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, child := range flat(next.object) {
+			box, ok := child.(*mkBox)
+			switch {
+			case !ok:
+				item := &doc{text: child.String()}
+				next.into.body = append(next.into.body, item)
+			case depth > 0 && next.depth >= depth:
+				item := &doc{text: "[...]"}
+				next.into.body = append(next.into.body, item)
+			default:
+				item := &doc{box: true}
+				next.into.body = append(next.into.body, item)
+				todo = append(todo, task{item, box.body, next.depth + 1})
+			}
+		}
+	}
+	measure(root)
+	return root
+}
+
+// measure computes the widths of a doc and everything inside it.
+func measure(root *doc) {
+	var order []*doc
+	todo := []*doc{root}
+	for len(todo) > 0 {
+		item := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		order = append(order, item)
+		todo = append(todo, item.body...)
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		item := order[i]
+		if !item.box {
+			item.width = utf8.RuneCountInString(item.text)
+			continue
+		}
+		item.width = 2
+		for j, child := range item.body {
+			if j > 0 {
+				item.width++
+			}
+			item.width += child.width
+		}
+	}
+}
+
+type printer struct {
+	options Options
+	buf     strings.Builder
+	column  int
+}
+
+func (ctx *printer) fits(width int) bool {
+	return ctx.options.Width <= 0 || ctx.column+width <= ctx.options.Width
+}
+
+func (ctx *printer) write(text string) {
+	ctx.buf.WriteString(text)
+	ctx.column += utf8.RuneCountInString(text)
+}
+
+func (ctx *printer) newline(indent int) {
+	ctx.buf.WriteString("\n")
+	ctx.buf.WriteString(strings.Repeat(" ", indent))
+	ctx.column = indent
+}
+
+// seq writes a sequence of terms, starting at the current column and
+// wrapping onto new lines at the given indentation.
+func (ctx *printer) seq(items []*doc, indent int) {
+	for i, item := range items {
+		if i > 0 {
+			fresh := ctx.options.Width <= 0 ||
+				indent+item.width <= ctx.options.Width
+			if ctx.fits(1+item.width) || (item.box && !fresh) {
+				ctx.write(" ")
+			} else {
+				ctx.newline(indent)
+			}
+		}
+		ctx.term(item, indent)
+	}
+}
+
+// term writes a term, breaking it open if it is a box that doesn't
+// fit on the current line.
+func (ctx *printer) term(item *doc, indent int) {
+	if !item.box {
+		ctx.write(item.text)
+		return
+	}
+	if ctx.fits(item.width) || len(item.body) == 0 {
+		ctx.flat(item)
+		return
+	}
+	inner := indent + ctx.options.Indent
+	ctx.write("[")
+	ctx.newline(inner)
+	ctx.seq(item.body, inner)
+	ctx.newline(indent)
+	ctx.write("]")
+}
+
+// flat writes a term on one line, without recursion.
+func (ctx *printer) flat(item *doc) {
+	todo := []*doc{item}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if !next.box {
+			ctx.write(next.text)
+			continue
+		}
+		ctx.write("[")
+		todo = append(todo, &doc{text: "]"})
+		for i := len(next.body) - 1; i >= 0; i-- {
+			todo = append(todo, next.body[i])
+			if i > 0 {
+				todo = append(todo, &doc{text: " "})
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package abc
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	narrow := Options{Width: 10, Indent: 2}
+	cases := []struct {
+		src     string
+		options Options
+		want    string
+	}{
+		{"a b [c d] e", Options{}, "a b [c d] e"},
+		{"a b c d e f a b c d e f", narrow, "a b c d e\nf a b c d\ne f"},
+		{"a b [c d e f a b c d e f] e", narrow,
+			"a b [\n  c d e f\n  a b c d\n  e f\n] e"},
+		{"a [b [c d e f] c] e", Options{Width: 10, Indent: 4},
+			"a [\n    b [\n        c\n        d\n        e\n        f\n    ] c\n] e"},
+		{"[] a", Options{Width: 1, Indent: 2}, "[]\na"},
+		{"[a [b [c]]] d", Options{Depth: 1}, "[a [...]] d"},
+		{"[a [b [c]]] d", Options{Depth: 2}, "[a [b [...]]] d"},
+		{"[a [b [c]]] d", Options{Depth: 3}, "[a [b [c]]] d"},
+	}
+	for _, test := range cases {
+		object := MustRead(test.src)
+		got := Format(object, test.options)
+		if got != test.want {
+			t.Errorf("`%s` with %+v: expected\n%s\nbut got\n%s", test.src, test.options, test.want, got)
+			continue
+		}
+		if strings.Contains(got, "...") {
+			continue
+		}
+		if !Equals(MustRead(got), object) {
+			t.Errorf("`%s` with %+v: `%s` doesn't read back as the same program", test.src, test.options, got)
+		}
+	}
+}
+
+// FormatSource keeps annotations, words and local variables as they
+// are written, only moving the spaces and line breaks between terms.
+func TestFormatSource(t *testing.T) {
+	options := Options{Width: 12, Indent: 2}
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"", "\n"},
+		{"a  b\n\n c", "a b c\n"},
+		{"(pair) [A] \\x y -> swap-apply x  (second   note)\n[y x]",
+			"(pair) [A]\n\\x y ->\nswap-apply x\n(second note)\n[y x]\n"},
+		{"a b c d e f a b c d e f [a b c (note) d e f a b] (x)",
+			"a b c d e f\na b c d e f [\n  a b c\n  (note) d e\n  f a b\n] (x)\n"},
+	}
+	for _, test := range cases {
+		got, err := FormatSource(test.src, options)
+		if err != nil {
+			t.Errorf("`%s`: %s", test.src, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("`%s`: expected\n%s\nbut got\n%s", test.src, test.want, got)
+		}
+	}
+	for _, src := range []string{"[a", "a]", "[[a] b", "(a"} {
+		_, err := FormatSource(src, options)
+		if err == nil {
+			t.Errorf("`%s`: expected an error", src)
+		}
+	}
+}
+
+// Formatting formatted source changes nothing, and the source reads
+// back as the same program, whatever the width.
+func TestFormatSourceIdempotent(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		src := randomProgram(r, r.Intn(30), 4)
+		options := Options{Width: r.Intn(30), Indent: r.Intn(5)}
+		once, err := FormatSource(src, options)
+		if err != nil {
+			t.Fatalf("`%s`: %s", src, err)
+		}
+		twice, err := FormatSource(once, options)
+		if err != nil {
+			t.Fatalf("`%s`: %s", once, err)
+		}
+		if once != twice {
+			t.Fatalf("`%s` with %+v: formatted as\n%s\nand then as\n%s", src, options, once, twice)
+		}
+		if !Equals(MustRead(once), MustRead(src)) {
+			t.Fatalf("`%s` with %+v: `%s` doesn't read back as the same program", src, options, once)
+		}
+	}
+}
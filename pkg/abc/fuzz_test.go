@@ -0,0 +1,193 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+// fuzzSize bounds the size of the results that are checked, since
+// comparing or printing a result takes time in proportion to it.
+const fuzzSize = 1 << 16
+
+// FuzzRead checks that a program is read back the same from both its
+// text and its formatted source.
+func FuzzRead(f *testing.F) {
+	for _, test := range readCases(f) {
+		f.Add(test.Program)
+		f.Add(test.Result)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		object, err := Read(strings.NewReader(src))
+		if err != nil {
+			return
+		}
+		texts := []string{
+			object.String(),
+			Format(object, Options{Width: 20, Indent: 2}),
+		}
+		for _, text := range texts {
+			other, err := Read(strings.NewReader(text))
+			if err != nil {
+				t.Fatalf("`%s` can't be read back: %s", text, err)
+			}
+			if !Equals(object, other) {
+				t.Fatalf("`%s` is read back as `%s`", object, other)
+			}
+		}
+	})
+}
+
+// FuzzRewrite checks the invariants of rewriting a program with a
+// quota:
+//
+//   - With no quota, the program is left as it is.
+//   - Rewriting the result with more quota is the same as rewriting
+//     the program with the combined quota.
+//   - A compiled Program gives the same result as Rewrite.
+//   - Each step makes at most one new box.
+func FuzzRewrite(f *testing.F) {
+	for _, test := range readCases(f) {
+		quota := test.Quota
+		if quota > 255 {
+			quota = 255
+		}
+		f.Add(uint8(quota), test.Program)
+	}
+	f.Fuzz(func(t *testing.T, n uint8, src string) {
+		quota := int(n)
+		object, err := Read(strings.NewReader(src))
+		if err != nil {
+			return
+		}
+		rewrite := func(object Object, quota int) Object {
+			return RewriteWith(object, Config{Quota: quota, Loader: noWords})
+		}
+		if !Equals(rewrite(object, 0), object) {
+			t.Fatalf("`%s` changes with no quota", object)
+		}
+		result := rewrite(object, quota)
+		if size(result, fuzzSize) == fuzzSize {
+			return
+		}
+		half := rewrite(rewrite(object, quota/2), quota-quota/2)
+		if !Equals(result, half) {
+			msg := "`%s` becomes `%s` with a quota of %d, but `%s` in two halves"
+			t.Fatalf(msg, object, result, quota, half)
+		}
+		compiled := Compile(object).RewriteWith(Config{Quota: quota, Loader: noWords})
+		if !Equals(result, compiled) {
+			msg := "`%s` becomes `%s` with a quota of %d, but `%s` when compiled"
+			t.Fatalf(msg, object, result, quota, compiled)
+		}
+		for _, other := range []Object{result, compiled} {
+			if boxes(other) > boxes(object)+quota {
+				t.Fatalf("`%s` makes too many boxes with a quota of %d", object, quota)
+			}
+		}
+	})
+}
+
+// Nil objects are the empty program, rather than a panic.
+func TestNilObjects(t *testing.T) {
+	empty := Empty()
+	results := []Object{
+		Box(nil),
+		Cat(nil, nil),
+		Rewrite(nil, 10),
+		RewriteWith(nil, Config{Quota: 10, Loader: noWords}),
+		Compile(nil).Rewrite(10),
+	}
+	if !Equals(results[0], MustRead("[]")) {
+		t.Errorf("expected `[]`, but got `%s`", results[0])
+	}
+	for _, result := range results[1:] {
+		if !Equals(result, empty) {
+			t.Errorf("expected the empty program, but got `%s`", result)
+		}
+	}
+	if !Equals(nil, nil) || !Equals(nil, empty) || Equals(nil, Swap()) {
+		t.Errorf("nil isn't equal to the empty program alone")
+	}
+}
+
+// boxes counts the distinct boxes in an object. Boxes are shared by
+// `d`, so the text of an object can be much larger than the count.
+func boxes(object Object) int {
+	seen := make(map[Object]bool)
+	todo := []Object{object}
+	count := 0
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		switch next := next.(type) {
+		case *mkCat:
+			if !seen[next] {
+				seen[next] = true
+				todo = append(todo, next.fst, next.snd)
+			}
+		case *mkBox:
+			if !seen[next] {
+				seen[next] = true
+				count++
+				todo = append(todo, next.body)
+			}
+		}
+	}
+	return count
+}
+
+// size returns the number of objects in the text of an object, up to
+// a limit, counting a shared box each time it appears.
+func size(object Object, limit int) int {
+	memo := make(map[Object]int)
+	known := func(part Object) (int, bool) {
+		switch part.(type) {
+		case *mkCat, *mkBox:
+			count, ok := memo[part]
+			return count, ok
+		case opId:
+			return 0, true
+		default:
+			return 1, true
+		}
+	}
+	count, ok := known(object)
+	if ok {
+		return count
+	}
+	todo := []Object{object}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		if _, ok := memo[next]; ok {
+			todo = todo[:len(todo)-1]
+			continue
+		}
+		var parts []Object
+		count := 0
+		switch next := next.(type) {
+		case *mkCat:
+			parts = []Object{next.fst, next.snd}
+		case *mkBox:
+			parts = []Object{next.body}
+			count = 1
+		}
+		done := true
+		for _, part := range parts {
+			n, ok := known(part)
+			if !ok {
+				todo = append(todo, part)
+				done = false
+			}
+			count += n
+		}
+		if !done {
+			continue
+		}
+		if count > limit {
+			count = limit
+		}
+		memo[next] = count
+		todo = todo[:len(todo)-1]
+	}
+	return memo[object]
+}
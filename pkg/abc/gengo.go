@@ -0,0 +1,353 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateGo writes a Go source file for a package, containing an
+// Accelerator for a word with the given definition. The accelerator
+// is named for the word in camel case, so `swap-apply` becomes
+// `SwapApply`, and is registered with Loader.Accelerate.
+//
+// The definition is run on the values that it takes from the stack,
+// while it is known which box each `a` applies. When it applies a box
+// that comes from the stack, or reaches a word, the accelerator
+// returns the rest of the definition as code to run.
+func GenerateGo(pkg, word string, body Object) ([]byte, error) {
+	if !wordName.MatchString(word) {
+		return nil, fmt.Errorf("`%s` is not a word", word)
+	}
+	hole := false
+	Walk(body, func(object Object) bool {
+		_, hole = object.(mkHole)
+		return !hole
+	})
+	if hole {
+		return nil, fmt.Errorf("`%s` contains a placeholder", word)
+	}
+	ctx := &generator{name: exported(word)}
+	ctx.run(ctx.items(body))
+	src := ctx.source(pkg, word)
+	return format.Source([]byte(src))
+}
+
+// exported returns a word's name in camel case.
+func exported(word string) string {
+	var buf strings.Builder
+	for _, part := range strings.Split(word, "-") {
+		for i, char := range part {
+			if i == 0 {
+				char = unicode.ToUpper(char)
+			}
+			buf.WriteRune(char)
+		}
+	}
+	return buf.String()
+}
+
+const (
+	// genSteps bounds the steps run while generating code, since a
+	// definition may not terminate.
+	genSteps = 10000
+	// genSize bounds the length of a box body built while generating
+	// code. Longer ones are built when the accelerator runs.
+	genSize = 256
+)
+
+// A genValue is a box on the stack of a definition being compiled:
+// an input, a box whose code is known, or two boxes joined by `c`.
+// A value only becomes a Go expression if it has to.
+type genValue struct {
+	expr     string
+	static   bool
+	body     []genItem
+	fst, snd *genValue
+}
+
+// A genItem is a step of code: a primitive, the push of a value, an
+// annotation, or an object such as a word, where generating stops.
+type genItem struct {
+	op  byte
+	val *genValue
+	obj Object
+}
+
+type generator struct {
+	name   string
+	lits   []string
+	vars   map[string]string
+	stmts  []string
+	locals int
+	inputs int
+	stack  []*genValue
+	rest   []genItem
+}
+
+// items converts code to items, with its boxes as static values.
+func (ctx *generator) items(object Object) []genItem {
+	var out []genItem
+	for _, part := range flat(object) {
+		switch op := primitive(part); {
+		case op != 0:
+			out = append(out, genItem{op: op})
+		default:
+			box, ok := part.(*mkBox)
+			if ok {
+				val := &genValue{static: true, body: ctx.items(box.body)}
+				out = append(out, genItem{val: val})
+				continue
+			}
+			_, note := part.(mkNote)
+			if note {
+				out = append(out, genItem{op: 'n', obj: part})
+				continue
+			}
+			out = append(out, genItem{op: 'o', obj: part})
+		}
+	}
+	return out
+}
+
+func (ctx *generator) push(val *genValue) {
+	ctx.stack = append(ctx.stack, val)
+}
+
+// pop pops a value, taking another input from the stack if need be.
+func (ctx *generator) pop() *genValue {
+	n := len(ctx.stack)
+	if n == 0 {
+		name := fmt.Sprintf("in%d", ctx.inputs)
+		ctx.inputs++
+		return &genValue{expr: name}
+	}
+	val := ctx.stack[n-1]
+	ctx.stack = ctx.stack[:n-1]
+	return val
+}
+
+// run runs code on the stack of values, until it finishes or reaches
+// a step that can't be run ahead of time.
+func (ctx *generator) run(code []genItem) {
+	work := [][]genItem{code}
+	steps := 0
+	for len(work) > 0 {
+		top := work[len(work)-1]
+		if len(top) == 0 {
+			work = work[:len(work)-1]
+			continue
+		}
+		item := top[0]
+		work[len(work)-1] = top[1:]
+		steps++
+		if steps > genSteps || !ctx.step(item, &work) {
+			ctx.rest = append(ctx.rest, item)
+			for i := len(work) - 1; i >= 0; i-- {
+				ctx.rest = append(ctx.rest, work[i]...)
+			}
+			return
+		}
+	}
+}
+
+// step runs one item, returning false if it can't.
+func (ctx *generator) step(item genItem, work *[][]genItem) bool {
+	switch item.op {
+	case 0:
+		ctx.push(item.val)
+	case 'a':
+		val := ctx.pop()
+		if !val.static {
+			ctx.push(val)
+			return false
+		}
+		*work = append(*work, val.body)
+	case 'b':
+		val := ctx.pop()
+		ctx.push(&genValue{static: true, body: []genItem{{val: val}}})
+	case 'c':
+		snd, fst := ctx.pop(), ctx.pop()
+		if fst.static && snd.static && len(fst.body)+len(snd.body) <= genSize {
+			body := append(append([]genItem(nil), fst.body...), snd.body...)
+			ctx.push(&genValue{static: true, body: body})
+			break
+		}
+		ctx.push(&genValue{fst: fst, snd: snd})
+	case 'd':
+		val := ctx.pop()
+		ctx.push(val)
+		ctx.push(val)
+	case 'e':
+		ctx.pop()
+	case 'f':
+		snd, fst := ctx.pop(), ctx.pop()
+		ctx.push(snd)
+		ctx.push(fst)
+	case 'n':
+	default:
+		return false
+	}
+	return true
+}
+
+// local assigns an expression to a new variable, and returns its name.
+func (ctx *generator) local(expr string) string {
+	name := fmt.Sprintf("x%d", ctx.locals)
+	ctx.locals++
+	ctx.stmts = append(ctx.stmts, fmt.Sprintf("%s := %s", name, expr))
+	return name
+}
+
+// literal returns a global variable holding the object with the
+// given text.
+func (ctx *generator) literal(text string) string {
+	if ctx.vars == nil {
+		ctx.vars = make(map[string]string)
+	}
+	name, ok := ctx.vars[text]
+	if !ok {
+		prefix := strings.ToLower(ctx.name[:1]) + ctx.name[1:]
+		name = fmt.Sprintf("%sLit%d", prefix, len(ctx.lits))
+		ctx.vars[text] = name
+		ctx.lits = append(ctx.lits, text)
+	}
+	return name
+}
+
+// text returns the text of a static value, if it refers to no value
+// from the stack.
+func (ctx *generator) text(val *genValue) (string, bool) {
+	if !val.static {
+		return "", false
+	}
+	var parts []string
+	for _, item := range val.body {
+		switch item.op {
+		case 0:
+			text, ok := ctx.text(item.val)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, "["+text+"]")
+		case 'n', 'o':
+			parts = append(parts, item.obj.String())
+		default:
+			parts = append(parts, string(item.op))
+		}
+	}
+	return strings.Join(parts, " "), true
+}
+
+// expr returns a Go expression for a value, giving it a variable the
+// first time it's needed.
+func (ctx *generator) expr(val *genValue) string {
+	if val.expr != "" {
+		return val.expr
+	}
+	if val.fst != nil {
+		expr := fmt.Sprintf("abc.Box(abc.Cat(abc.Body(%s), abc.Body(%s)))",
+			ctx.expr(val.fst), ctx.expr(val.snd))
+		val.expr = ctx.local(expr)
+		return val.expr
+	}
+	text, ok := ctx.text(val)
+	if ok {
+		val.expr = ctx.literal("[" + text + "]")
+		return val.expr
+	}
+	var parts []string
+	for _, item := range val.body {
+		parts = append(parts, ctx.item(item))
+	}
+	expr := fmt.Sprintf("abc.Box(abc.Cat(%s))", strings.Join(parts, ", "))
+	val.expr = ctx.local(expr)
+	return val.expr
+}
+
+// item returns a Go expression for an item.
+func (ctx *generator) item(item genItem) string {
+	switch item.op {
+	case 0:
+		return ctx.expr(item.val)
+	case 'n', 'o':
+		return ctx.literal(item.obj.String())
+	default:
+		return map[byte]string{
+			'a': "abc.App()",
+			'b': "abc.Wrap()",
+			'c': "abc.Compose()",
+			'd': "abc.Copy()",
+			'e': "abc.Drop()",
+			'f': "abc.Swap()",
+		}[item.op]
+	}
+}
+
+// source writes the file for the accelerator.
+func (ctx *generator) source(pkg, word string) string {
+	var outs []string
+	for _, val := range ctx.stack {
+		outs = append(outs, ctx.expr(val))
+	}
+	rest := "abc.Empty()"
+	if len(ctx.rest) > 0 {
+		var parts []string
+		for _, item := range ctx.rest {
+			parts = append(parts, ctx.item(item))
+		}
+		rest = fmt.Sprintf("abc.Cat(%s)", strings.Join(parts, ", "))
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "// Code generated by abc gen-go from `%s`. DO NOT EDIT.\n\n", word)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import \"github.com/xkapastel/go-abc/pkg/abc\"\n\n")
+	for _, text := range ctx.lits {
+		name := ctx.vars[text]
+		fmt.Fprintf(&buf, "var %s = abc.MustRead(%s)\n", name, strconv.Quote(text))
+	}
+	fmt.Fprintf(&buf, "\n// %s runs `%s`; see abc.Accelerator.\n", ctx.name, word)
+	fmt.Fprintf(&buf, "func %s(stack []abc.Object) ([]abc.Object, abc.Object, bool) {\n", ctx.name)
+	fmt.Fprintf(&buf, "n := len(stack)\n")
+	if ctx.inputs > 0 {
+		fmt.Fprintf(&buf, "if n < %d {\nreturn nil, nil, false\n}\n", ctx.inputs)
+		for i := 0; i < ctx.inputs; i++ {
+			fmt.Fprintf(&buf, "in%d := stack[n-%d]\n", i, i+1)
+			fmt.Fprintf(&buf, "if abc.KindOf(in%d) != abc.KindBox {\nreturn nil, nil, false\n}\n", i)
+		}
+	}
+	for _, stmt := range ctx.stmts {
+		fmt.Fprintf(&buf, "%s\n", stmt)
+	}
+	keep := fmt.Sprintf("n-%d", ctx.inputs)
+	if ctx.inputs == 0 {
+		keep = "n"
+	}
+	out := fmt.Sprintf("stack[:%s:%s]", keep, keep)
+	if len(outs) > 0 {
+		out = fmt.Sprintf("append(%s, %s)", out, strings.Join(outs, ", "))
+	}
+	fmt.Fprintf(&buf, "return %s, %s, true\n}\n", out, rest)
+	return buf.String()
+}
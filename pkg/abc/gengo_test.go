@@ -0,0 +1,113 @@
+package abc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// genSamples are definitions to generate accelerators for, with the
+// arguments to run each on. Some are too few, so that the accelerator
+// gives up and the definition is used.
+var genSamples = []struct {
+	word string
+	body string
+	args []string
+}{
+	{"swap-apply", "f a", []string{"[d] [e]", "[[b] [c]] [d] [[e] a]", "[e]", ""}},
+	{"twice", "d c a", []string{"[[b] c] [b]", "[] [[] b] [d c]", "[]"}},
+	{"dup-drop", "d e", []string{"[a]", ""}},
+	{"rotate", "[f] c f [f] c a", []string{"[b] [c] [d]", "[e] [f] [[a]] [[b]]", "[b] [c]"}},
+	{"wrap-twice", "b b [[d]] c", []string{"[e]", "[[[]]]"}},
+	{"constant", "[[a] [b]] [c] f e d", []string{"", "[e]"}},
+	{"call-word", "swap-apply [e] a", []string{"[d] [e] [[]]", "[[c]] [d]"}},
+	{"apply-input", "[b] c a e", []string{"[[d]] [[e] [e]]", "[d] [[]]"}},
+}
+
+// Accelerators generated for the samples are built and run in a module
+// of their own, and must give what rewriting the definitions gives.
+func TestGenerateGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a Go program")
+	}
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	gomod := strings.TrimSpace(string(out))
+	if err != nil || gomod == "" || gomod == os.DevNull {
+		t.Skip("not in a Go module")
+	}
+	root := filepath.Dir(gomod)
+	words := t.TempDir()
+	dir := t.TempDir()
+	write := func(path, src string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(src), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "go.mod"), fmt.Sprintf(
+		"module gentest\n\ngo 1.22\n\nrequire github.com/xkapastel/go-abc v0.0.0\n\nreplace github.com/xkapastel/go-abc => %s\n",
+		root))
+	var main strings.Builder
+	main.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n")
+	main.WriteString("\t\"gentest/accel\"\n\t\"github.com/xkapastel/go-abc/pkg/abc\"\n)\n\n")
+	main.WriteString("func main() {\n\tloader := abc.NewLoader(os.Args[1])\n")
+	var srcs []string
+	var want []Object
+	plain := NewLoader(words)
+	for _, sample := range genSamples {
+		write(filepath.Join(words, sample.word), sample.body)
+		src, err := GenerateGo("accel", sample.word, MustRead(sample.body))
+		if err != nil {
+			t.Fatalf("`%s`: %s", sample.word, err)
+		}
+		name := strings.Replace(sample.word, "-", "_", -1) + ".go"
+		write(filepath.Join(dir, "accel", name), string(src))
+		fmt.Fprintf(&main, "\tloader.Accelerate(%q, accel.%s)\n", sample.word, exported(sample.word))
+	}
+	main.WriteString("\tconfig := abc.Config{Quota: 10000, Loader: loader}\n\tok := false\n")
+	for _, sample := range genSamples {
+		for _, args := range sample.args {
+			src := strings.TrimSpace(args + " " + sample.word)
+			fmt.Fprintf(&main, "\t_, _, ok = accel.%s(abc.Parts(abc.MustRead(%q)))\n", exported(sample.word), args)
+			fmt.Fprintf(&main, "\tfmt.Println(ok, abc.RewriteWith(abc.MustRead(%q), config))\n", src)
+			got := RewriteWith(MustRead(src), Config{Quota: 10000, Loader: plain})
+			srcs = append(srcs, src)
+			want = append(want, got)
+		}
+	}
+	main.WriteString("}\n")
+	write(filepath.Join(dir, "main.go"), main.String())
+	cmd := exec.Command("go", "run", ".", words)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d results, but got:\n%s", len(want), out)
+	}
+	ran := make(map[string]bool)
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 2)
+		ran[fields[0]] = true
+		got := ""
+		if len(fields) == 2 {
+			got = fields[1]
+		}
+		if !Equals(MustRead(got), want[i]) {
+			t.Errorf("`%s`: expected `%s`, but the accelerator gives `%s`", srcs[i], want[i], got)
+		}
+	}
+	if !ran["true"] || !ran["false"] {
+		t.Errorf("expected some accelerators to run and some to give up, but got:\n%s", out)
+	}
+}
@@ -0,0 +1,38 @@
+package abc
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// noWords is a loader that can't load any words, so that tests never
+// depend on the files in the current directory.
+var noWords = NewLoader(os.DevNull)
+
+// randomProgram returns the text of a random program of primitives
+// and boxes, with boxes nested at most depth deep.
+func randomProgram(r *rand.Rand, size, depth int) string {
+	var parts []string
+	for i := 0; i < size; i++ {
+		n := r.Intn(9)
+		switch {
+		case n < 6:
+			parts = append(parts, string("abcdef"[n]))
+		case depth > 0:
+			parts = append(parts, "["+randomProgram(r, r.Intn(5), depth-1)+"]")
+		default:
+			parts = append(parts, "[]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// settles predicates a program that a strategy rewrites to the same
+// result with a quota and with more, which is how the tests tell the
+// programs that terminate.
+func settles(object Object, strategy Strategy, quota int) bool {
+	fst := RewriteWith(object, Config{Quota: quota, Strategy: strategy, Loader: noWords})
+	snd := RewriteWith(object, Config{Quota: 2*quota + 1, Strategy: strategy, Loader: noWords})
+	return Equals(fst, snd)
+}
@@ -0,0 +1,798 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Effect describes what a program does to the stack: the values
+// it consumes, and the values it produces in their place. Effects are
+// polymorphic over the rest of the stack, and values that a program
+// applies or concatenates are known to be boxes, whose own effects
+// are part of the description.
+//
+// Effects are written like the primitive rules, with the inputs on
+// the left of `--` and the outputs on the right. `[A]` is a box about
+// which nothing else is known, and `..S` names the rest of a stack
+// when it matters. For example, the effect of `f` is
+//
+//	[A] [B] -- [B] [A]
+//
+// and the effect of `a` is
+//
+//	..S [..S -- ..T] -- ..T
+type Effect struct {
+	in, out row
+}
+
+// Inputs is the number of values an effect consumes.
+func (effect Effect) Inputs() int { return len(effect.in.items) }
+
+// Outputs is the number of values an effect produces.
+func (effect Effect) Outputs() int { return len(effect.out.items) }
+
+func (effect Effect) String() string {
+	ctx := &effectPrinter{
+		count:  make(map[int]int),
+		visits: make(map[int]int),
+		names:  make(map[int]string),
+	}
+	ctx.countEffect(effect)
+	var buf strings.Builder
+	ctx.write(&buf, effect)
+	return buf.String()
+}
+
+// An InferError reports an object whose effect could not be applied
+// to the stack, which means the program would get stuck there.
+type InferError struct {
+	// Object is the primitive, word or link at fault.
+	Object Object
+	// Term is the position of the object in the innermost word,
+	// or in the program, counting from 1 in reading order.
+	Term int
+	// Words lists the words being expanded, outermost first.
+	Words []string
+	// Reason says what went wrong.
+	Reason string
+	// Pos is where the object was written, if that is known.
+	Pos Pos
+}
+
+func (err *InferError) Error() string {
+	var buf []string
+	if err.Pos.Line > 0 {
+		buf = append(buf, err.Pos.String()+": ")
+	}
+	for _, word := range err.Words {
+		buf = append(buf, fmt.Sprintf("in `%s`: ", word))
+	}
+	where := strings.Join(buf, "")
+	msg := "%sterm %d `%s`: %s"
+	return fmt.Sprintf(msg, where, err.Term, err.Object, err.Reason)
+}
+
+// Infer computes the effect of a program, resolving words through
+// files in the current directory.
+func Infer(object Object) (Effect, error) {
+	return defaultLoader.Infer(object)
+}
+
+// Infer computes the effect of a program, resolving words through
+// the loader.
+func (loader *Loader) Infer(object Object) (Effect, error) {
+	ctx := newInfer(loader)
+	effect, err := ctx.program(object)
+	if err != nil {
+		return Effect{}, err
+	}
+	return ctx.zonkEffect(effect), nil
+}
+
+// A term is the type of a value on the stack: either a variable,
+// standing for any value, or a box with a known effect.
+type term struct {
+	id  int
+	box *scheme
+}
+
+// A scheme is the effect of a box. The variables in bound are local
+// to the box, and are renamed each time it is used, so that a box
+// may be used on stacks of different shapes. A closed scheme binds
+// every variable in its effect, which has already been zonked, so
+// it is left as it is by substitution and renaming.
+type scheme struct {
+	effect Effect
+	bound  map[int]bool
+	closed bool
+}
+
+// A row is a stack: some values on top of a variable standing for
+// the rest. Items are ordered from the bottom up, and are never
+// modified in place, since rows share them.
+type row struct {
+	rest  int
+	items []*term
+}
+
+func (stack row) push(object *term) row {
+	n := len(stack.items)
+	items := append(stack.items[:n:n], object)
+	return row{stack.rest, items}
+}
+
+// frame is a program or word definition being inferred.
+type frame struct {
+	word string
+	term int
+}
+
+type infer struct {
+	loader *Loader
+	next   int
+	terms  map[int]*term
+	rows   map[int]row
+	words  map[string]*scheme
+	frames []*frame
+	// rigid holds the variables of a declared effect while it is
+	// checked, which may not be bound to anything but themselves.
+	rigid map[int]bool
+}
+
+func newInfer(loader *Loader) *infer {
+	return &infer{
+		loader: loader,
+		terms:  make(map[int]*term),
+		rows:   make(map[int]row),
+		words:  make(map[string]*scheme),
+		frames: []*frame{{}},
+		rigid:  make(map[int]bool),
+	}
+}
+
+func (ctx *infer) fresh() int {
+	ctx.next++
+	return ctx.next
+}
+
+func (ctx *infer) newVar() *term {
+	return &term{id: ctx.fresh()}
+}
+
+func (ctx *infer) fail(object Object, reason string) error {
+	var words []string
+	for _, frame := range ctx.frames[1:] {
+		words = append(words, frame.word)
+	}
+	top := ctx.frames[len(ctx.frames)-1]
+	err := &InferError{
+		Object: object,
+		Term:   top.term,
+		Words:  words,
+		Reason: reason,
+	}
+	src := OriginOf(object)
+	if src != nil {
+		err.Pos = src.Pos
+	}
+	return err
+}
+
+// program infers the effect of a sequence of objects, starting from
+// a stack about which nothing is known. The bodies of boxes are
+// inferred in place of the box, with the enclosing sequences kept on
+// a stack of their own, so that deeply nested boxes don't exhaust
+// the goroutine's stack.
+func (ctx *infer) program(object Object) (Effect, error) {
+	type level struct {
+		in, stack row
+		rest      Object
+	}
+	var outer []level
+	in := row{rest: ctx.fresh()}
+	this := level{in, in, object}
+	for {
+		if this.rest == nil {
+			effect := Effect{this.in, this.stack}
+			n := len(outer)
+			if n == 0 {
+				return effect, nil
+			}
+			this = outer[n-1]
+			outer = outer[:n-1]
+			this.stack = this.stack.push(ctx.enclose(effect))
+			continue
+		}
+		var fst Object
+		cat, ok := this.rest.(*mkCat)
+		if ok {
+			fst, this.rest = cat.fst, cat.snd
+		} else {
+			fst, this.rest = this.rest, nil
+		}
+		var err error
+		note, ok := fst.(mkNote)
+		if ok && strings.HasPrefix(note.text, "type ") {
+			var rest Object = opId{}
+			if this.rest != nil {
+				rest = this.rest
+			}
+			this.stack, err = ctx.declare(note, this.stack, rest)
+			if err != nil {
+				return Effect{}, err
+			}
+			this.rest = nil
+			continue
+		}
+		box, ok := fst.(*mkBox)
+		if ok {
+			ctx.frames[len(ctx.frames)-1].term++
+			outer = append(outer, this)
+			in := row{rest: ctx.fresh()}
+			this = level{in, in, box.body}
+			continue
+		}
+		this.stack, err = ctx.step(fst, this.stack)
+		if err != nil {
+			return Effect{}, err
+		}
+	}
+}
+
+// enclose makes the value pushed by a box from the effect of its
+// body. Every variable in the effect is bound by the box.
+func (ctx *infer) enclose(effect Effect) *term {
+	effect = ctx.zonkEffect(effect)
+	bound := make(map[int]bool)
+	ctx.freeEffect(effect, bound)
+	return &term{box: &scheme{effect, bound, true}}
+}
+
+func (ctx *infer) step(object Object, stack row) (row, error) {
+	_, ok := object.(opId)
+	if ok {
+		return stack, nil
+	}
+	top := ctx.frames[len(ctx.frames)-1]
+	top.term++
+	switch object := object.(type) {
+	case opApp:
+		stack, fst := ctx.pop(stack)
+		effect := ctx.expect(fst)
+		err := ctx.unifyRow(stack, effect.in)
+		if err != nil {
+			return stack, ctx.fail(object, err.Error())
+		}
+		return effect.out, nil
+	case opBox:
+		stack, fst := ctx.pop(stack)
+		fst = ctx.resolveTerm(fst)
+		rest := ctx.fresh()
+		in := row{rest: rest}
+		effect := Effect{in, in.push(fst)}
+		bound := map[int]bool{rest: true}
+		closed := fst.box != nil && fst.box.closed
+		box := &term{box: &scheme{effect, bound, closed}}
+		return stack.push(box), nil
+	case opCat:
+		stack, snd := ctx.pop(stack)
+		stack, fst := ctx.pop(stack)
+		lhs := ctx.expect(fst)
+		rhs := ctx.expect(snd)
+		err := ctx.unifyRow(lhs.out, rhs.in)
+		if err != nil {
+			return stack, ctx.fail(object, err.Error())
+		}
+		effect := ctx.zonkEffect(Effect{lhs.in, rhs.out})
+		free := make(map[int]bool)
+		ctx.freeTerm(ctx.zonkTerm(fst), free)
+		ctx.freeTerm(ctx.zonkTerm(snd), free)
+		bound := make(map[int]bool)
+		ctx.freeEffect(effect, bound)
+		for id := range free {
+			delete(bound, id)
+		}
+		box := &term{box: &scheme{effect, bound, len(free) == 0}}
+		return stack.push(box), nil
+	case opCopy:
+		stack, fst := ctx.pop(stack)
+		return stack.push(fst).push(fst), nil
+	case opDrop:
+		stack, _ = ctx.pop(stack)
+		return stack, nil
+	case opSwap:
+		stack, snd := ctx.pop(stack)
+		stack, fst := ctx.pop(stack)
+		return stack.push(snd).push(fst), nil
+	case mkNote:
+		return stack, nil
+	case mkVar:
+		word, err := ctx.word(object)
+		if err != nil {
+			return stack, err
+		}
+		effect := ctx.instantiate(word)
+		err = ctx.unifyRow(stack, effect.in)
+		if err != nil {
+			return stack, ctx.fail(object, err.Error())
+		}
+		return effect.out, nil
+	default:
+		return stack, ctx.fail(object, "effect is unknown")
+	}
+}
+
+// word infers the effect of a word's definition, once per word.
+func (ctx *infer) word(object mkVar) (*scheme, error) {
+	word, ok := ctx.words[object.name]
+	if ok {
+		return word, nil
+	}
+	for _, frame := range ctx.frames {
+		if frame.word == object.name {
+			return nil, ctx.fail(object, "word refers to itself")
+		}
+	}
+	body, err := ctx.loader.Load(object.name)
+	if err != nil {
+		return nil, ctx.fail(object, err.Error())
+	}
+	ctx.frames = append(ctx.frames, &frame{word: object.name})
+	effect, err := ctx.program(body)
+	ctx.frames = ctx.frames[:len(ctx.frames)-1]
+	if err != nil {
+		return nil, err
+	}
+	word = ctx.enclose(effect).box
+	ctx.words[object.name] = word
+	return word, nil
+}
+
+// pop takes the top value from a stack, which must then have come
+// from below the program's inputs if the stack has no values.
+func (ctx *infer) pop(stack row) (row, *term) {
+	stack = ctx.resolveRow(stack)
+	n := len(stack.items)
+	if n == 0 {
+		object := ctx.newVar()
+		rest := ctx.fresh()
+		ctx.rows[stack.rest] = row{rest, []*term{object}}
+		return row{rest: rest}, object
+	}
+	object := stack.items[n-1]
+	return row{stack.rest, stack.items[: n-1 : n-1]}, object
+}
+
+// expect returns the effect of a value that is about to be applied
+// or concatenated, which makes it a box if it was not one already.
+func (ctx *infer) expect(object *term) Effect {
+	object = ctx.resolveTerm(object)
+	if object.box != nil {
+		return ctx.instantiate(object.box)
+	}
+	effect := Effect{row{rest: ctx.fresh()}, row{rest: ctx.fresh()}}
+	ctx.terms[object.id] = &term{box: &scheme{effect: effect}}
+	return effect
+}
+
+// instantiate renames the bound variables of a scheme. The bound
+// variables of boxes inside it are renamed too, so that no two
+// instances of a box share a name.
+func (ctx *infer) instantiate(box *scheme) Effect {
+	effect := ctx.zonkEffect(box.effect)
+	names := make(map[int]int)
+	for id := range box.bound {
+		names[id] = ctx.fresh()
+	}
+	return ctx.renameEffect(effect, names)
+}
+
+func (ctx *infer) renameEffect(effect Effect, names map[int]int) Effect {
+	in := ctx.renameRow(effect.in, names)
+	out := ctx.renameRow(effect.out, names)
+	return Effect{in, out}
+}
+
+func (ctx *infer) renameRow(stack row, names map[int]int) row {
+	rest, ok := names[stack.rest]
+	if !ok {
+		rest = stack.rest
+	}
+	items := make([]*term, len(stack.items))
+	for i, object := range stack.items {
+		items[i] = ctx.renameTerm(object, names)
+	}
+	return row{rest, items}
+}
+
+func (ctx *infer) renameTerm(object *term, names map[int]int) *term {
+	if object.box == nil {
+		id, ok := names[object.id]
+		if !ok {
+			return object
+		}
+		return &term{id: id}
+	}
+	if object.box.closed {
+		return object
+	}
+	inner := make(map[int]int)
+	for id, name := range names {
+		inner[id] = name
+	}
+	bound := make(map[int]bool)
+	for id := range object.box.bound {
+		inner[id] = ctx.fresh()
+		bound[inner[id]] = true
+	}
+	effect := ctx.renameEffect(object.box.effect, inner)
+	return &term{box: &scheme{effect, bound, false}}
+}
+
+func (ctx *infer) resolveTerm(object *term) *term {
+	for object.box == nil {
+		next, ok := ctx.terms[object.id]
+		if !ok {
+			break
+		}
+		object = next
+	}
+	return object
+}
+
+func (ctx *infer) resolveRow(stack row) row {
+	for {
+		next, ok := ctx.rows[stack.rest]
+		if !ok {
+			return stack
+		}
+		n := len(next.items)
+		items := append(next.items[:n:n], stack.items...)
+		stack = row{next.rest, items}
+	}
+}
+
+// zonkEffect applies every substitution made so far to an effect.
+func (ctx *infer) zonkEffect(effect Effect) Effect {
+	return Effect{ctx.zonkRow(effect.in), ctx.zonkRow(effect.out)}
+}
+
+func (ctx *infer) zonkRow(stack row) row {
+	stack = ctx.resolveRow(stack)
+	items := make([]*term, len(stack.items))
+	for i, object := range stack.items {
+		items[i] = ctx.zonkTerm(object)
+	}
+	return row{stack.rest, items}
+}
+
+func (ctx *infer) zonkTerm(object *term) *term {
+	object = ctx.resolveTerm(object)
+	if object.box == nil || object.box.closed {
+		return object
+	}
+	effect := ctx.zonkEffect(object.box.effect)
+	return &term{box: &scheme{effect, object.box.bound, false}}
+}
+
+// freeEffect adds the free variables of a zonked effect to a set.
+func (ctx *infer) freeEffect(effect Effect, set map[int]bool) {
+	ctx.freeRow(effect.in, set)
+	ctx.freeRow(effect.out, set)
+}
+
+func (ctx *infer) freeRow(stack row, set map[int]bool) {
+	set[stack.rest] = true
+	for _, object := range stack.items {
+		ctx.freeTerm(object, set)
+	}
+}
+
+func (ctx *infer) freeTerm(object *term, set map[int]bool) {
+	if object.box == nil {
+		set[object.id] = true
+		return
+	}
+	if object.box.closed {
+		return
+	}
+	inner := make(map[int]bool)
+	ctx.freeEffect(object.box.effect, inner)
+	for id := range inner {
+		if !object.box.bound[id] {
+			set[id] = true
+		}
+	}
+}
+
+func (ctx *infer) unifyRow(lhs, rhs row) error {
+	lhs = ctx.resolveRow(lhs)
+	rhs = ctx.resolveRow(rhs)
+	i := len(lhs.items) - 1
+	j := len(rhs.items) - 1
+	for i >= 0 && j >= 0 {
+		err := ctx.unifyTerm(lhs.items[i], rhs.items[j])
+		if err != nil {
+			return err
+		}
+		i--
+		j--
+	}
+	lhs = ctx.resolveRow(row{lhs.rest, lhs.items[: i+1 : i+1]})
+	rhs = ctx.resolveRow(row{rhs.rest, rhs.items[: j+1 : j+1]})
+	switch {
+	case len(lhs.items) > 0 && len(rhs.items) > 0:
+		return ctx.unifyRow(lhs, rhs)
+	case len(lhs.items) == 0 && len(rhs.items) == 0:
+		if lhs.rest == rhs.rest {
+			return nil
+		}
+		if ctx.rigid[lhs.rest] {
+			return ctx.bindRow(rhs.rest, lhs)
+		}
+		return ctx.bindRow(lhs.rest, rhs)
+	case len(lhs.items) == 0:
+		return ctx.bindRow(lhs.rest, rhs)
+	default:
+		return ctx.bindRow(rhs.rest, lhs)
+	}
+}
+
+func (ctx *infer) bindRow(id int, stack row) error {
+	if ctx.rigid[id] {
+		return fmt.Errorf("stacks don't match")
+	}
+	if ctx.occursRow(id, stack) {
+		return fmt.Errorf("stack would have to contain itself")
+	}
+	ctx.rows[id] = stack
+	return nil
+}
+
+func (ctx *infer) unifyTerm(lhs, rhs *term) error {
+	lhs = ctx.resolveTerm(lhs)
+	rhs = ctx.resolveTerm(rhs)
+	switch {
+	case lhs.box == nil && rhs.box == nil:
+		if lhs.id == rhs.id {
+			return nil
+		}
+		if ctx.rigid[lhs.id] {
+			return ctx.bindTerm(rhs.id, lhs)
+		}
+		return ctx.bindTerm(lhs.id, rhs)
+	case lhs.box == nil:
+		return ctx.bindTerm(lhs.id, rhs)
+	case rhs.box == nil:
+		return ctx.bindTerm(rhs.id, lhs)
+	default:
+		fst := ctx.instantiate(lhs.box)
+		snd := ctx.instantiate(rhs.box)
+		err := ctx.unifyRow(fst.in, snd.in)
+		if err != nil {
+			return err
+		}
+		return ctx.unifyRow(fst.out, snd.out)
+	}
+}
+
+func (ctx *infer) bindTerm(id int, object *term) error {
+	if ctx.rigid[id] {
+		return fmt.Errorf("values don't match")
+	}
+	if ctx.occursTerm(id, object) {
+		return fmt.Errorf("box would have to contain itself")
+	}
+	ctx.terms[id] = object
+	return nil
+}
+
+func (ctx *infer) occursRow(id int, stack row) bool {
+	stack = ctx.resolveRow(stack)
+	if stack.rest == id {
+		return true
+	}
+	for _, object := range stack.items {
+		if ctx.occursTerm(id, object) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *infer) occursTerm(id int, object *term) bool {
+	object = ctx.resolveTerm(object)
+	if object.box == nil {
+		return object.id == id
+	}
+	if object.box.closed {
+		return false
+	}
+	effect := object.box.effect
+	return ctx.occursRow(id, effect.in) || ctx.occursRow(id, effect.out)
+}
+
+// effectPrinter names the variables of an effect as it is written.
+// A stack variable that only links the two sides of one effect is
+// left out, and a box about which nothing is known is written with
+// a single name. The variables bound by a box are local to it, so
+// they are named afresh each time the box is written.
+type effectPrinter struct {
+	count  map[int]int
+	visits map[int]int
+	names  map[int]string
+	rows   int
+	terms  int
+}
+
+// A printTask is a part of an effect still to be written: a value,
+// the name of a stack variable, or else punctuation.
+type printTask struct {
+	object *term
+	rest   int
+	row    bool
+	punct  string
+}
+
+// countEffect counts the appearances of each variable in an effect,
+// and of each box that binds variables.
+func (ctx *effectPrinter) countEffect(effect Effect) {
+	todo := []row{effect.in, effect.out}
+	for len(todo) > 0 {
+		stack := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		ctx.count[stack.rest]++
+		for _, object := range stack.items {
+			if object.box == nil {
+				ctx.count[object.id]++
+				continue
+			}
+			ctx.visits[boxID(object.box)]++
+			todo = append(todo, object.box.effect.in, object.box.effect.out)
+		}
+	}
+}
+
+// boxID identifies a box by the least variable it binds, or zero if
+// it binds none. Substitution copies a box's scheme, but the copies
+// bind the same variables, which no other box binds.
+func boxID(box *scheme) int {
+	least := 0
+	for id := range box.bound {
+		if least == 0 || id < least {
+			least = id
+		}
+	}
+	return least
+}
+
+func (ctx *effectPrinter) name(id int, rest bool) string {
+	name, ok := ctx.names[id]
+	if ok {
+		return name
+	}
+	var n int
+	var letters string
+	if rest {
+		n = ctx.rows
+		ctx.rows++
+		letters = "STUVW"
+	} else {
+		n = ctx.terms
+		ctx.terms++
+		letters = "ABCDEFGHIJKLMNOPQR"
+	}
+	name = string(letters[n%len(letters)])
+	if n >= len(letters) {
+		name = fmt.Sprintf("%s%d", name, n/len(letters))
+	}
+	ctx.names[id] = name
+	return name
+}
+
+// write writes an effect without recursion, so that the effects of
+// boxes nested arbitrarily deep can be printed. Variables are named
+// in the order they are written.
+func (ctx *effectPrinter) write(w io.StringWriter, effect Effect) {
+	todo := ctx.effect(nil, effect, nil)
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		switch {
+		case next.object != nil:
+			todo = ctx.term(todo, w, next.object)
+		case next.row:
+			w.WriteString(".." + ctx.name(next.rest, true))
+		default:
+			w.WriteString(next.punct)
+		}
+	}
+}
+
+// effect adds the tasks that write an effect to todo. The effect is
+// either the whole effect being printed, or the effect of box. A
+// variable appears exactly n times within its effect when it appears
+// n times in the whole effect, or n times in each appearance of the
+// box that binds it.
+func (ctx *effectPrinter) effect(todo []printTask, effect Effect, box *scheme) []printTask {
+	only := func(id, n int) bool {
+		if box == nil || !box.bound[id] {
+			return ctx.count[id] == n
+		}
+		return ctx.count[id] == n*ctx.visits[boxID(box)]
+	}
+	in, out := effect.in, effect.out
+	hide := in.rest == out.rest && only(in.rest, 2)
+	parts := ctx.row(nil, in, hide)
+	if len(parts) > 0 {
+		parts = append(parts, printTask{punct: " "})
+	}
+	parts = append(parts, printTask{punct: "--"})
+	rhs := ctx.row(nil, out, hide)
+	if len(rhs) > 0 {
+		parts = append(parts, printTask{punct: " "})
+		parts = append(parts, rhs...)
+	}
+	for i := len(parts) - 1; i >= 0; i-- {
+		todo = append(todo, parts[i])
+	}
+	return todo
+}
+
+// row appends the tasks that write a stack to parts, in order.
+func (ctx *effectPrinter) row(parts []printTask, stack row, hide bool) []printTask {
+	if !hide {
+		parts = append(parts, printTask{rest: stack.rest, row: true})
+	}
+	for i, object := range stack.items {
+		if i > 0 || !hide {
+			parts = append(parts, printTask{punct: " "})
+		}
+		parts = append(parts, printTask{object: object})
+	}
+	return parts
+}
+
+// term writes the start of a value, adding the tasks that write the
+// rest of it to todo.
+func (ctx *effectPrinter) term(todo []printTask, w io.StringWriter, object *term) []printTask {
+	if object.box == nil {
+		w.WriteString("[" + ctx.name(object.id, false) + "]")
+		return todo
+	}
+	box := object.box
+	for id := range box.bound {
+		delete(ctx.names, id)
+	}
+	in, out := box.effect.in, box.effect.out
+	opaque := len(in.items) == 0 && len(out.items) == 0 &&
+		in.rest != out.rest &&
+		box.bound[in.rest] && box.bound[out.rest]
+	if opaque {
+		w.WriteString("[" + ctx.name(in.rest, false) + "]")
+		return todo
+	}
+	w.WriteString("[")
+	todo = append(todo, printTask{punct: "]"})
+	return ctx.effect(todo, box.effect, box)
+}
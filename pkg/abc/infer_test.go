@@ -0,0 +1,55 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInferExamples(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"a", "..S [..S -- ..T] -- ..T"},
+		{"b", "[A] -- [-- [A]]"},
+		{"c", "[..S -- ..T] [..T -- ..U] -- [..S -- ..U]"},
+		{"d", "[A] -- [A] [A]"},
+		{"e", "[A] --"},
+		{"f", "[A] [B] -- [B] [A]"},
+		{"", "--"},
+		{"[] []", "-- [--] [--]"},
+		{"f f", "[A] [B] -- [A] [B]"},
+		{"b a", "[A] -- [A]"},
+		{"[f] c", "[..S -- ..T [A] [B]] -- [..S -- ..T [B] [A]]"},
+		{"[[b] a] d c", "-- [[A] -- [-- [-- [A]]]]"},
+		{"[b] d a f c", "[..S [[A] -- [-- [A]]] -- ..T] -- [..S -- ..T]"},
+		{"[[] f] a", "[A] -- [--] [A]"},
+	}
+	for _, test := range cases {
+		effect, err := noWords.Infer(MustRead(test.src))
+		if err != nil {
+			t.Errorf("`%s`: %s", test.src, err)
+			continue
+		}
+		if effect.String() != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, effect)
+		}
+	}
+	_, err := noWords.Infer(MustRead("[d a] d a"))
+	if err == nil || !strings.Contains(err.Error(), "term 3 `a`") {
+		t.Errorf("`[d a] d a`: expected an error at term 3, but got %v", err)
+	}
+}
+
+func TestInferDeep(t *testing.T) {
+	const n = 1000000
+	src := strings.Repeat("[", n) + strings.Repeat("]", n)
+	effect, err := noWords.Infer(MustRead(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--" + strings.Repeat(" [--", n) + strings.Repeat("]", n)
+	if effect.String() != want {
+		t.Fatalf("expected %d nested boxes", n)
+	}
+}
@@ -0,0 +1,208 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package lambda
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xkapastel/go-abc/pkg/abc"
+)
+
+// Compile translates a term into an ABC program that pushes the
+// term's value. A function becomes a box which, applied with `a` to
+// a stack holding its argument, leaves its result in the argument's
+// place. Free variables become words, and bound variables become the
+// local variables of the reader, which removes them. The abstraction
+// of variables is left to the reader, so that there is only one: it
+// drops a variable that isn't used, and copies one only when it is
+// used more than once.
+func Compile(term Term) (abc.Object, error) {
+	set := make(map[string]bool)
+	term.free(set)
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	word := regexp.MustCompile("^[a-z][a-z0-9-]+$")
+	for _, name := range names {
+		if !word.MatchString(name) {
+			msg := "`%s` is not bound, and is not an ABC word"
+			return nil, fmt.Errorf(msg, name)
+		}
+	}
+	ctx := &compiler{taken: set}
+	code := ctx.compile(term, nil)
+	return abc.Read(strings.NewReader(render(code)))
+}
+
+// An item is a step of compiled code: a primitive, a box, the push of
+// a variable, or the binding of local variable.
+type item struct {
+	kind int
+	op   byte
+	name string
+	body []item
+}
+
+const (
+	opItem = iota
+	boxItem
+	pushItem
+	bindItem
+)
+
+func op(code byte) item     { return item{kind: opItem, op: code} }
+func box(body []item) item  { return item{kind: boxItem, body: body} }
+func push(name string) item { return item{kind: pushItem, name: name} }
+func bind(name string) item { return item{kind: bindItem, name: name} }
+
+func join(parts ...[]item) []item {
+	var code []item
+	for _, part := range parts {
+		code = append(code, part...)
+	}
+	return code
+}
+
+// compiler renames bound variables as it goes, since local variables
+// of the reader are more restricted than those of a term. Names in
+// taken are never handed out, so that free variables are not
+// captured.
+type compiler struct {
+	names int
+	taken map[string]bool
+}
+
+func (ctx *compiler) fresh() string {
+	for {
+		name := nameOf(ctx.names)
+		ctx.names++
+		if !ctx.taken[name] {
+			return name
+		}
+	}
+}
+
+// nameOf returns the i-th name for a variable. Every such name is a
+// local variable of the reader, but not an ABC word.
+func nameOf(i int) string {
+	const letters = "xyzuvwghijklmnopqrst"
+	name := string(letters[i%len(letters)])
+	return name + strings.Repeat("'", i/len(letters))
+}
+
+// compile translates a term, where env maps its bound variables to
+// the local variables that replace them.
+func (ctx *compiler) compile(term Term, env map[string]string) []item {
+	switch term := term.(type) {
+	case mkVar:
+		return []item{push(rename(env, term.name))}
+	case mkFun:
+		name := ctx.fresh()
+		body := ctx.compile(term.body, extend(env, term.param, name))
+		return []item{box(join([]item{bind(name)}, body))}
+	case mkApp:
+		fun := ctx.compile(term.fun, env)
+		arg := ctx.compile(term.arg, env)
+		return join(arg, fun, []item{op('a')})
+	case mkLet:
+		val := ctx.compile(term.val, env)
+		name := ctx.fresh()
+		body := ctx.compile(term.body, extend(env, term.name, name))
+		scope := box(join([]item{bind(name)}, body))
+		return join(val, []item{scope, op('a')})
+	case mkCode:
+		return []item{box(renameAll(env, term.code))}
+	default:
+		panic("lambda: unknown term")
+	}
+}
+
+func rename(env map[string]string, name string) string {
+	next, ok := env[name]
+	if ok {
+		return next
+	}
+	return name
+}
+
+func renameAll(env map[string]string, code []item) []item {
+	var out []item
+	for _, it := range code {
+		switch it.kind {
+		case pushItem:
+			it = push(rename(env, it.name))
+		case boxItem:
+			it = box(renameAll(env, it.body))
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+func extend(env map[string]string, from, to string) map[string]string {
+	next := make(map[string]string)
+	for key, val := range env {
+		next[key] = val
+	}
+	next[from] = to
+	return next
+}
+
+// render writes code as ABC text without recursion, so that boxes
+// nested arbitrarily deep can be written. Each task is code to write,
+// followed by punctuation.
+func render(code []item) string {
+	type task struct {
+		code  []item
+		punct string
+	}
+	var buf strings.Builder
+	todo := []task{{code: code}}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if len(next.code) == 0 {
+			buf.WriteString(next.punct)
+			continue
+		}
+		it := next.code[0]
+		todo = append(todo, task{next.code[1:], next.punct})
+		if len(next.code) > 1 {
+			todo = append(todo, task{punct: " "})
+		}
+		switch it.kind {
+		case opItem:
+			buf.WriteByte(it.op)
+		case boxItem:
+			buf.WriteString("[")
+			todo = append(todo, task{it.body, "]"})
+		case bindItem:
+			buf.WriteString("\\" + it.name + " ->")
+		default:
+			buf.WriteString(it.name)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,150 @@
+package lambda
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xkapastel/go-abc/pkg/abc"
+)
+
+// noWords is a loader that can't load any words, so that tests never
+// depend on the files in the current directory.
+var noWords = abc.NewLoader(os.DevNull)
+
+// compile compiles the source of a term, failing the test if it can't.
+func compile(t *testing.T, src string) abc.Object {
+	t.Helper()
+	term, err := Parse(src)
+	if err != nil {
+		t.Fatalf("`%s`: %s", src, err)
+	}
+	object, err := Compile(term)
+	if err != nil {
+		t.Fatalf("`%s`: %s", src, err)
+	}
+	return object
+}
+
+// church defines Church numerals and the functions on them, to be
+// used by the term that follows.
+const church = `
+let zero = \f x. x in
+let succ = \n f x. f (n f x) in
+let two = \f x. f (f x) in
+let add = \m n f x. m f (n f x) in
+let mul = \m n f. m (n f) in
+let pow = \b e. e b in
+let three = succ two in
+let pair = \a b k. k a b in
+let fst = \p. p (\a b. a) in
+let snd = \p. p (\a b. b) in
+let pred = \n. fst (n (\p. pair (snd p) (succ (snd p))) (pair zero zero)) in
+`
+
+// A numeral n, applied to `[b]` and then to `[]`, wraps `[]` in n
+// more boxes.
+func TestCompileNumerals(t *testing.T) {
+	cases := []struct {
+		src  string
+		want int
+	}{
+		{"zero", 0},
+		{"two", 2},
+		{"three", 3},
+		{"add two two", 4},
+		{"mul two three", 6},
+		{"pow two three", 8},
+		{"pred three", 2},
+		{"pred (mul three three)", 8},
+		{`(\x. x) two`, 2},
+		{`let id = \x. x in id id two`, 2},
+	}
+	for _, test := range cases {
+		code := compile(t, church+test.src)
+		program := abc.Cat(abc.MustRead("[] [b]"), code, abc.MustRead("a a"))
+		got := abc.RewriteWith(program, abc.Config{Quota: 100000, Loader: noWords})
+		want := strings.Repeat("[", test.want+1) + strings.Repeat("]", test.want+1)
+		if got.String() != want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, want, got)
+		}
+	}
+}
+
+// Each function is applied to the boxes on the stack before it, the
+// last of them first.
+func TestCompileFunctions(t *testing.T) {
+	cases := []struct {
+		args string
+		src  string
+		want string
+	}{
+		{"[e]", `\x. x`, "[e]"},
+		{"[d] [e]", `\x y. x`, "[e]"},
+		{"[d] [e]", `\x y. y`, "[d]"},
+		{"[d] [b]", `\f x. f x`, "[[d]]"},
+		{"[d] [b]", `\f x. f (f x)`, "[[[d]]]"},
+		{"[b] [d]", `\x f. f x`, "[[d]]"},
+		{"[e]", `\x. let k = \a b. a in k x x`, "[e]"},
+	}
+	for _, test := range cases {
+		code := compile(t, test.src)
+		apply := strings.Repeat(" a", strings.Count(test.args, "] [")+1)
+		program := abc.Cat(abc.MustRead(test.args), code, abc.MustRead(apply))
+		got := abc.RewriteWith(program, abc.Config{Quota: 1000, Loader: noWords})
+		if !abc.Equals(got, abc.MustRead(test.want)) {
+			t.Errorf("`%s` on `%s`: expected `%s`, but got `%s`", test.src, test.args, test.want, got)
+		}
+	}
+}
+
+// Compiled code shuffles no more than it has to: a variable that isn't
+// used is dropped, and one that is used once is never copied. Each
+// term's code has at most the given number of primitives and boxes.
+func TestCompileSize(t *testing.T) {
+	cases := []struct {
+		src  string
+		most int
+	}{
+		{`\x. x`, 1},
+		{`\x y. x`, 6},
+		{`\x y. y`, 3},
+		{`\x y. y x`, 6},
+		{`\x y. x y`, 5},
+		{`\a b c. a`, 11},
+		{`\a b c. b`, 8},
+		{`\a b c. a b c`, 13},
+	}
+	for _, test := range cases {
+		code := compile(t, test.src).String()
+		size := strings.Count(code, "[")
+		for _, op := range "abcdef" {
+			size += strings.Count(code, string(op))
+		}
+		if size > test.most {
+			t.Errorf("`%s`: expected at most %d objects, but got `%s`", test.src, test.most, code)
+		}
+		if strings.Contains(code, "d") {
+			t.Errorf("`%s`: expected no copies, but got `%s`", test.src, code)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{`\x. y`, `\x. Foo x`} {
+		term, err := Parse(src)
+		if err != nil {
+			t.Fatalf("`%s`: %s", src, err)
+		}
+		_, err = Compile(term)
+		if err == nil {
+			t.Errorf("`%s`: expected an error for a free variable", src)
+		}
+	}
+	for _, src := range []string{`\. x`, `let x = in x`, `(x`, `x)`} {
+		_, err := Parse(src)
+		if err == nil {
+			t.Errorf("`%s`: expected a syntax error", src)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xkapastel/go-abc/pkg/abc"
+)
+
+// Decompile is the inverse of Compile: it recovers a term from an ABC
+// program that pushes a single value. The program is run on symbolic
+// values, so stack shuffling disappears, boxes that act as functions
+// of one argument become lambdas, and values that are copied are
+// bound with let. Boxes that don't act as functions are shown as ABC
+// code in brackets.
+func Decompile(object abc.Object) (Term, error) {
+	ctx := &decompiler{fuel: decompileFuel}
+	frame := &frame{ctx: ctx, closed: true}
+	err := frame.run(instrsOf(object))
+	if err != nil {
+		return nil, err
+	}
+	if len(frame.stack) != 1 {
+		msg := "the program pushes %d values, not one"
+		return nil, fmt.Errorf(msg, len(frame.stack))
+	}
+	out := ctx.term(frame.stack[0])
+	return frame.bind(out), nil
+}
+
+// Explain describes an ABC program in terms of named variables,
+// resolving words through files in the current directory.
+func Explain(object abc.Object) string {
+	return ExplainWith(object, abc.NewLoader("."))
+}
+
+// ExplainWith describes an ABC program in terms of named variables.
+// The program's inputs are named from the bottom of the stack to the
+// top, followed by `->` and the values it leaves on the stack, such
+// as `y x -> x, y` for `f`. A program that needs no inputs and pushes
+// one value is shown as a term, like Decompile. Unlike Decompile, a
+// word runs its definition, which is resolved through the loader. The
+// inputs are any boxes at all, so a program that applies one of them,
+// which could use the whole stack, can't be explained. Such programs,
+// and those that get stuck, use a word that can't be loaded, or don't
+// terminate quickly, are shown unchanged.
+func ExplainWith(object abc.Object, loader *abc.Loader) string {
+	ctx := &decompiler{fuel: decompileFuel, loader: loader}
+	frame := &frame{ctx: ctx}
+	err := frame.run(instrsOf(object))
+	if err != nil {
+		return object.String()
+	}
+	var outs []string
+	var terms []Term
+	for _, value := range frame.stack {
+		term := ctx.term(value)
+		terms = append(terms, term)
+		outs = append(outs, term.String())
+	}
+	var ins []string
+	for i := len(frame.inputs) - 1; i >= 0; i-- {
+		ins = append(ins, frame.inputs[i])
+	}
+	var lets []string
+	for _, bound := range frame.needs(terms...) {
+		lets = append(lets, fmt.Sprintf("let %s = %s in ", bound.name, bound.val))
+	}
+	body := strings.Join(lets, "") + strings.Join(outs, ", ")
+	if len(ins) == 0 {
+		return body
+	}
+	return strings.TrimSpace(strings.Join(ins, " ") + " -> " + body)
+}
+
+// decompileFuel bounds the number of steps taken to explain a
+// program, so that programs which don't terminate are given up on.
+const decompileFuel = 10000
+
+// A value is an item on the symbolic stack: either a term, or a box
+// whose code is known. An input is a term naming a box from below
+// the program's stack, which is not known to be a function.
+type value struct {
+	term  Term
+	code  []instr
+	input bool
+}
+
+// An instr is a step of code being decompiled: a primitive, the push
+// of a value, a word, or else an object that can't be decompiled,
+// such as a link, which is stuck.
+type instr struct {
+	op    byte
+	push  *value
+	word  string
+	stuck abc.Object
+}
+
+// mkCode is a box that is not a function of one argument, shown as
+// ABC code in brackets. Its code may push variables bound outside it.
+type mkCode struct{ code []item }
+
+func (term mkCode) String() string { return "[" + render(term.code) + "]" }
+func (term mkCode) free(set map[string]bool) {
+	todo := [][]item{term.code}
+	for len(todo) > 0 {
+		code := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, it := range code {
+			switch it.kind {
+			case pushItem:
+				set[it.name] = true
+			case boxItem:
+				todo = append(todo, it.body)
+			}
+		}
+	}
+}
+
+type binding struct {
+	name string
+	val  Term
+}
+
+// decompiler holds the state shared by every frame of a decompiled
+// program: the remaining fuel, the names already handed out, and the
+// loader that words are run from. Without a loader, a word pushes
+// itself as a free variable, as Compile makes it.
+type decompiler struct {
+	fuel   int
+	names  int
+	loader *abc.Loader
+}
+
+// fresh returns a name for a new variable.
+func (ctx *decompiler) fresh() string {
+	name := nameOf(ctx.names)
+	ctx.names++
+	return name
+}
+
+// A frame is the symbolic stack of a program or a box body. When the
+// frame is closed, code that pops an empty stack is an error;
+// otherwise, a new input is named.
+type frame struct {
+	ctx    *decompiler
+	stack  []*value
+	inputs []string
+	lets   []binding
+	closed bool
+}
+
+var (
+	errStuck = errors.New("the program would get stuck")
+	errInput = errors.New("the program applies one of its inputs")
+)
+
+func (frame *frame) pop() (*value, error) {
+	n := len(frame.stack)
+	if n > 0 {
+		value := frame.stack[n-1]
+		frame.stack = frame.stack[:n-1]
+		return value, nil
+	}
+	if frame.closed {
+		return nil, errStuck
+	}
+	name := frame.ctx.fresh()
+	frame.inputs = append(frame.inputs, name)
+	return &value{term: mkVar{name}, input: true}, nil
+}
+
+func (frame *frame) push(value *value) {
+	frame.stack = append(frame.stack, value)
+}
+
+func (frame *frame) run(code []instr) error {
+	for _, it := range code {
+		frame.ctx.fuel--
+		if frame.ctx.fuel < 0 {
+			return errors.New("the program takes too long to explain")
+		}
+		if it.stuck != nil {
+			return errStuck
+		}
+		if it.push != nil {
+			frame.push(it.push)
+			continue
+		}
+		if it.word != "" {
+			err := frame.call(it.word)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := frame.step(it.op)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call runs a word: its definition, if there is a loader, and
+// otherwise the push of a free variable.
+func (frame *frame) call(name string) error {
+	loader := frame.ctx.loader
+	if loader == nil {
+		frame.push(&value{term: mkVar{name}})
+		return nil
+	}
+	def, err := loader.Load(name)
+	if err != nil {
+		return fmt.Errorf("can't load `%s`: %s", name, err)
+	}
+	return frame.run(instrsOf(def))
+}
+
+func (frame *frame) step(op byte) error {
+	switch op {
+	case 'a':
+		fun, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		if fun.term == nil {
+			return frame.run(fun.code)
+		}
+		if fun.input {
+			return errInput
+		}
+		arg, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		term := mkApp{fun.term, frame.ctx.term(arg)}
+		frame.push(&value{term: term})
+	case 'b':
+		val, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		frame.push(&value{code: []instr{{push: val}}})
+	case 'c':
+		snd, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		fst, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		code := append(append([]instr{}, instrs(fst)...), instrs(snd)...)
+		frame.push(&value{code: code})
+	case 'd':
+		val, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		switch val.term.(type) {
+		case nil, mkVar, mkCode:
+		default:
+			name := frame.ctx.fresh()
+			frame.lets = append(frame.lets, binding{name, val.term})
+			val = &value{term: mkVar{name}}
+		}
+		frame.push(val)
+		frame.push(val)
+	case 'e':
+		_, err := frame.pop()
+		if err != nil {
+			return err
+		}
+	case 'f':
+		snd, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		fst, err := frame.pop()
+		if err != nil {
+			return err
+		}
+		frame.push(snd)
+		frame.push(fst)
+	default:
+		return errStuck
+	}
+	return nil
+}
+
+// instrs returns code that behaves as a value does when applied.
+func instrs(val *value) []instr {
+	if val.term == nil {
+		return val.code
+	}
+	return []instr{{push: val}, {op: 'a'}}
+}
+
+// needs returns the lets of a frame that some terms refer to, in
+// the order they were bound.
+func (frame *frame) needs(terms ...Term) []binding {
+	used := make(map[string]bool)
+	for _, term := range terms {
+		term.free(used)
+	}
+	var out []binding
+	for i := len(frame.lets) - 1; i >= 0; i-- {
+		bound := frame.lets[i]
+		if used[bound.name] {
+			bound.val.free(used)
+			out = append([]binding{bound}, out...)
+		}
+	}
+	return out
+}
+
+// bind wraps a term in the lets of a frame that it refers to.
+func (frame *frame) bind(term Term) Term {
+	lets := frame.needs(term)
+	for i := len(lets) - 1; i >= 0; i-- {
+		term = mkLet{lets[i].name, lets[i].val, term}
+	}
+	return term
+}
+
+// term converts a value to a term. A box becomes a lambda when its
+// code, run on a stack holding only its argument, leaves exactly one
+// value; otherwise it is shown as code.
+func (ctx *decompiler) term(val *value) Term {
+	if val.term != nil {
+		return val.term
+	}
+	names := ctx.names
+	param := ctx.fresh()
+	inner := &frame{ctx: ctx, closed: true}
+	inner.push(&value{term: mkVar{param}})
+	err := inner.run(val.code)
+	if err == nil && len(inner.stack) == 1 {
+		out := ctx.term(inner.stack[0])
+		return mkFun{param, inner.bind(out)}
+	}
+	ctx.names = names
+	return mkCode{items(val.code)}
+}
+
+// items converts code being decompiled back to compiled code,
+// keeping the boxes still to be converted on a stack of their own.
+func items(code []instr) []item {
+	type level struct {
+		code []instr
+		out  []item
+	}
+	this := level{code: code}
+	var outer []level
+	for {
+		if len(this.code) == 0 {
+			n := len(outer)
+			if n == 0 {
+				return this.out
+			}
+			body := this.out
+			this = outer[n-1]
+			outer = outer[:n-1]
+			this.out = append(this.out, box(body))
+			continue
+		}
+		it := this.code[0]
+		this.code = this.code[1:]
+		switch {
+		case it.stuck != nil:
+			this.out = append(this.out, push(it.stuck.String()))
+		case it.word != "":
+			this.out = append(this.out, push(it.word))
+		case it.push == nil:
+			this.out = append(this.out, op(it.op))
+		case it.push.term == nil:
+			outer = append(outer, this)
+			this = level{code: it.push.code}
+		default:
+			term := it.push.term
+			set := make(map[string]bool)
+			term.free(set)
+			ctx := &compiler{taken: set}
+			this.out = append(this.out, ctx.compile(term, nil)...)
+		}
+	}
+}
+
+// primitives maps the kinds of ABC's primitives to their code.
+var primitives = map[abc.Kind]byte{
+	abc.KindApp:     'a',
+	abc.KindWrap:    'b',
+	abc.KindCompose: 'c',
+	abc.KindCopy:    'd',
+	abc.KindDrop:    'e',
+	abc.KindSwap:    'f',
+}
+
+// instrsOf converts an ABC object to code, walking boxes with a stack
+// of their own rather than recursion. Words are left to be run, and
+// annotations are dropped.
+func instrsOf(object abc.Object) []instr {
+	type level struct {
+		parts []abc.Object
+		code  []instr
+	}
+	this := level{parts: abc.Parts(object)}
+	var outer []level
+	for {
+		if len(this.parts) == 0 {
+			n := len(outer)
+			if n == 0 {
+				return this.code
+			}
+			body := this.code
+			if body == nil {
+				body = []instr{}
+			}
+			this = outer[n-1]
+			outer = outer[:n-1]
+			this.code = append(this.code, instr{push: &value{code: body}})
+			continue
+		}
+		part := this.parts[0]
+		this.parts = this.parts[1:]
+		kind := abc.KindOf(part)
+		code, ok := primitives[kind]
+		switch {
+		case ok:
+			this.code = append(this.code, instr{op: code})
+		case kind == abc.KindBox:
+			outer = append(outer, this)
+			this = level{parts: abc.Parts(abc.Body(part))}
+		case kind == abc.KindWord:
+			this.code = append(this.code, instr{word: abc.Name(part)})
+		case kind == abc.KindNote, kind == abc.KindEmpty:
+		default:
+			this.code = append(this.code, instr{stuck: part})
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package lambda
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xkapastel/go-abc/pkg/abc"
+)
+
+func TestDecompile(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`\f x. f (f x)`, `\x. \y. x (x y)`},
+		{`\x y. y x`, `\x. \y. y x`},
+		{`\x. x`, `\x. x`},
+		{`\x y z. x z (y z)`, `\x. \y. \z. x z (y z)`},
+		{`\x. foo-bar x x`, `\x. foo-bar x x`},
+		{`\x. let y = x x in y y`, `\x. let y = x x in y y`},
+		{`let two = \f x. f (f x) in two two`, `\x. \y. x (x (x (x y)))`},
+	}
+	for _, test := range cases {
+		term, err := Decompile(compile(t, test.src))
+		if err != nil {
+			t.Errorf("`%s`: %s", test.src, err)
+			continue
+		}
+		if term.String() != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, term)
+		}
+	}
+	for _, src := range []string{"f", "[] []", "#00ab", "[d a] d a"} {
+		_, err := Decompile(abc.MustRead(src))
+		if err == nil {
+			t.Errorf("`%s`: expected an error", src)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"f", "y x -> x, y"},
+		{"[f] a", "y x -> x, y"},
+		{"d", "x -> x, x"},
+		{"(type [A] -- [A] [A]) d", "x -> x, x"},
+		{"e", "x ->"},
+		{"b", "x -> [x]"},
+		{"c", "y x -> [y a x a]"},
+		{"[b [f a] c]", `\x. \y. y x`},
+		{"[[e]] a", "[e]"},
+		{"[a b c] [f] c", "[a b c f]"},
+		{"[#00ab] e", ""},
+		// An input may be any box, which `a` runs on the whole stack,
+		// so applying one is not an application to one argument.
+		{"a", "a"},
+		{"f a", "f a"},
+		{"d c a", "d c a"},
+		{"#00ab", "#00ab"},
+		{"[d a] d a", "[d a] d a"},
+	}
+	for _, test := range cases {
+		got := Explain(abc.MustRead(test.src))
+		if got != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+	}
+}
+
+// A word runs its definition, so it is explained as the code it
+// stands for, and a word that can't be loaded is stuck.
+func TestExplainWords(t *testing.T) {
+	dir := t.TempDir()
+	defs := map[string]string{"swap": "f", "dup-swap": "d swap", "loop": "loop"}
+	for name, src := range defs {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := abc.NewLoader(dir)
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"swap", "y x -> x, y"},
+		{"[e] swap", "x -> [e], x"},
+		{"dup-swap", "x -> x, x"},
+		{"[swap]", "[swap]"},
+		{"foo-bar f", "foo-bar f"},
+		{"loop", "loop"},
+	}
+	for _, test := range cases {
+		got := ExplainWith(abc.MustRead(test.src), loader)
+		if got != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+	}
+}
+
+func TestExplainDeep(t *testing.T) {
+	const depth = 1000000
+	src := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	object := abc.MustRead(src)
+	if Explain(object) != src {
+		t.Fatal("the nested boxes aren't shown as they are")
+	}
+	term, err := Decompile(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if term.String() != src {
+		t.Fatal("the nested boxes aren't decompiled as code")
+	}
+}
@@ -0,0 +1,289 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+// Package lambda is a small language with named variables, which
+// compiles to ABC. Its terms are
+//
+//	x                   a variable
+//	\x. body            a function of x
+//	\x y. body          the same as \x. \y. body
+//	fun arg             an application
+//	let x = val in body the same as (\x. body) val
+//	(term)              grouping
+//
+// Functions extend as far to the right as possible, and application
+// associates to the left. A variable that is not bound by any
+// function or let refers to the ABC word of the same name.
+package lambda
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// A Term is a program in the lambda language.
+type Term interface {
+	String() string
+	// free adds the variables that occur free in a term to a set.
+	free(map[string]bool)
+}
+
+type mkVar struct{ name string }
+type mkFun struct {
+	param string
+	body  Term
+}
+type mkApp struct{ fun, arg Term }
+type mkLet struct {
+	name      string
+	val, body Term
+}
+
+func (term mkVar) String() string { return term.name }
+func (term mkFun) String() string {
+	return fmt.Sprintf("\\%s. %s", term.param, term.body)
+}
+func (term mkApp) String() string {
+	fun := term.fun.String()
+	arg := term.arg.String()
+	switch term.fun.(type) {
+	case mkFun, mkLet:
+		fun = "(" + fun + ")"
+	}
+	switch term.arg.(type) {
+	case mkApp, mkFun, mkLet:
+		arg = "(" + arg + ")"
+	}
+	return fun + " " + arg
+}
+func (term mkLet) String() string {
+	return fmt.Sprintf("let %s = %s in %s", term.name, term.val, term.body)
+}
+
+func (term mkVar) free(set map[string]bool) { set[term.name] = true }
+func (term mkFun) free(set map[string]bool) {
+	inner := make(map[string]bool)
+	term.body.free(inner)
+	delete(inner, term.param)
+	for name := range inner {
+		set[name] = true
+	}
+}
+func (term mkApp) free(set map[string]bool) {
+	term.fun.free(set)
+	term.arg.free(set)
+}
+func (term mkLet) free(set map[string]bool) {
+	term.val.free(set)
+	mkFun{term.name, term.body}.free(set)
+}
+
+// Parse reads a term from its source text.
+func Parse(src string) (Term, error) {
+	tokens, err := scan(src)
+	if err != nil {
+		return nil, err
+	}
+	parser := &parser{tokens: tokens}
+	term, err := parser.term()
+	if err != nil {
+		return nil, err
+	}
+	if parser.peek() != "" {
+		return nil, fmt.Errorf("unexpected `%s`", parser.peek())
+	}
+	return term, nil
+}
+
+func isName(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char) ||
+		char == '_' || char == '-' || char == '\''
+}
+
+func scan(src string) ([]string, error) {
+	var tokens []string
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		char := runes[i]
+		switch {
+		case unicode.IsSpace(char):
+			i++
+		case char == '\\' || char == 'λ':
+			tokens = append(tokens, "\\")
+			i++
+		case char == '.' || char == '(' || char == ')' || char == '=':
+			tokens = append(tokens, string(char))
+			i++
+		case unicode.IsLetter(char) || char == '_':
+			j := i
+			for j < len(runes) && isName(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected `%c`", char)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+}
+
+func (parser *parser) peek() string {
+	if len(parser.tokens) == 0 {
+		return ""
+	}
+	return parser.tokens[0]
+}
+
+func (parser *parser) next() string {
+	token := parser.peek()
+	if len(parser.tokens) > 0 {
+		parser.tokens = parser.tokens[1:]
+	}
+	return token
+}
+
+func (parser *parser) expect(want string) error {
+	token := parser.next()
+	if token != want {
+		if token == "" {
+			return fmt.Errorf("expected `%s`", want)
+		}
+		return fmt.Errorf("expected `%s`, not `%s`", want, token)
+	}
+	return nil
+}
+
+func isVar(token string) bool {
+	if token == "" || token == "let" || token == "in" {
+		return false
+	}
+	char := []rune(token)[0]
+	return unicode.IsLetter(char) || char == '_'
+}
+
+func (parser *parser) name() (string, error) {
+	token := parser.next()
+	if !isVar(token) {
+		if token == "" {
+			return "", fmt.Errorf("expected a variable")
+		}
+		return "", fmt.Errorf("expected a variable, not `%s`", token)
+	}
+	return token, nil
+}
+
+func (parser *parser) term() (Term, error) {
+	switch parser.peek() {
+	case "\\":
+		parser.next()
+		var params []string
+		for isVar(parser.peek()) {
+			params = append(params, parser.next())
+		}
+		if len(params) == 0 {
+			return nil, fmt.Errorf("expected a variable after `\\`")
+		}
+		err := parser.expect(".")
+		if err != nil {
+			return nil, err
+		}
+		body, err := parser.term()
+		if err != nil {
+			return nil, err
+		}
+		for i := len(params) - 1; i >= 0; i-- {
+			body = mkFun{params[i], body}
+		}
+		return body, nil
+	case "let":
+		parser.next()
+		name, err := parser.name()
+		if err != nil {
+			return nil, err
+		}
+		err = parser.expect("=")
+		if err != nil {
+			return nil, err
+		}
+		val, err := parser.term()
+		if err != nil {
+			return nil, err
+		}
+		err = parser.expect("in")
+		if err != nil {
+			return nil, err
+		}
+		body, err := parser.term()
+		if err != nil {
+			return nil, err
+		}
+		return mkLet{name, val, body}, nil
+	default:
+		return parser.app()
+	}
+}
+
+func (parser *parser) app() (Term, error) {
+	fun, err := parser.atom()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token := parser.peek()
+		if !isVar(token) && token != "(" && token != "\\" && token != "let" {
+			return fun, nil
+		}
+		var arg Term
+		if token == "\\" || token == "let" {
+			arg, err = parser.term()
+		} else {
+			arg, err = parser.atom()
+		}
+		if err != nil {
+			return nil, err
+		}
+		fun = mkApp{fun, arg}
+	}
+}
+
+func (parser *parser) atom() (Term, error) {
+	token := parser.peek()
+	if token == "(" {
+		parser.next()
+		term, err := parser.term()
+		if err != nil {
+			return nil, err
+		}
+		err = parser.expect(")")
+		if err != nil {
+			return nil, err
+		}
+		return term, nil
+	}
+	name, err := parser.name()
+	if err != nil {
+		return nil, err
+	}
+	return mkVar{name}, nil
+}
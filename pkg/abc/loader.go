@@ -0,0 +1,212 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A Loader resolves words to their definitions, which are read from
+// files in a directory. Definitions are cached, and a word whose
+// definition refers back to itself is an error. A Loader may be used
+// by multiple goroutines at once.
+//
+// When a file changes, the loader notices the next time the word is
+// loaded, rereading it and forgetting every word that refers to it,
+// directly or not. Changes are noticed by a file's size and
+// modification time, so a change that keeps both is missed.
+type Loader struct {
+	// Interval is how long a cached definition is trusted before its
+	// file is checked for changes again.
+	Interval time.Duration
+	dir      string
+	lock     sync.Mutex
+	cache    map[string]*entry
+	users    map[string]map[string]bool
+	epoch    int
+	accel    map[string]Accelerator
+}
+
+type entry struct {
+	body    Object
+	err     error
+	deps    []string
+	size    int64
+	modTime time.Time
+	checked time.Time
+	epoch   int
+}
+
+// cycleError reports a word whose definition refers back to itself.
+type cycleError struct{ name string }
+
+func (err cycleError) Error() string {
+	return fmt.Sprintf("`%s` contains a cycle", err.name)
+}
+
+// NewLoader creates a loader for the words defined in a directory,
+// checking cached definitions for changes at most once a second.
+func NewLoader(dir string) *Loader {
+	return &Loader{
+		Interval: time.Second,
+		dir:      dir,
+		cache:    make(map[string]*entry),
+		users:    make(map[string]map[string]bool),
+		accel:    make(map[string]Accelerator),
+	}
+}
+
+// defaultLoader resolves words for callers that don't provide their
+// own loader, using files in the current directory.
+var defaultLoader = NewLoader(".")
+
+// Load returns the definition of a word.
+func (loader *Loader) Load(name string) (Object, error) {
+	loader.lock.Lock()
+	defer loader.lock.Unlock()
+	loader.epoch++
+	return loader.load(name, make(map[string]bool))
+}
+
+// Refresh checks every cached definition for changes now, rather
+// than waiting for the interval to pass.
+func (loader *Loader) Refresh() {
+	loader.lock.Lock()
+	defer loader.lock.Unlock()
+	loader.epoch++
+	var names []string
+	for name, cached := range loader.cache {
+		cached.checked = time.Time{}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		cached, ok := loader.cache[name]
+		if ok {
+			loader.stale(name, cached)
+		}
+	}
+}
+
+// load reads a definition along with every definition it refers to,
+// so that cycles are found when a word is first loaded. The words
+// currently being loaded by this call are marked in cycle.
+func (loader *Loader) load(name string, cycle map[string]bool) (Object, error) {
+	cached, ok := loader.cache[name]
+	if ok && !loader.stale(name, cached) {
+		return cached.body, cached.err
+	}
+	if cycle[name] {
+		return nil, cycleError{name}
+	}
+	cached = &entry{epoch: loader.epoch, checked: time.Now()}
+	cached.size, cached.modTime = loader.stat(name)
+	cached.body, cached.err = loader.readFile(name)
+	if cached.err == nil {
+		Walk(cached.body, func(object Object) bool {
+			word, ok := object.(mkVar)
+			if ok {
+				cached.deps = append(cached.deps, word.name)
+			}
+			return true
+		})
+		cycle[name] = true
+		for _, dep := range cached.deps {
+			users, ok := loader.users[dep]
+			if !ok {
+				users = make(map[string]bool)
+				loader.users[dep] = users
+			}
+			users[name] = true
+			_, err := loader.load(dep, cycle)
+			_, ok = err.(cycleError)
+			if ok && cached.err == nil {
+				cached.body, cached.err = nil, err
+			}
+		}
+		cycle[name] = false
+	}
+	loader.cache[name] = cached
+	return cached.body, cached.err
+}
+
+// stale predicates a cached definition whose file, or the file of
+// any word it refers to, has changed. Each entry is checked at most
+// once per interval, and at most once per call to Load. Stale entries
+// are forgotten, along with the words that refer to them.
+func (loader *Loader) stale(name string, cached *entry) bool {
+	if cached.epoch == loader.epoch {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(cached.checked) < loader.Interval {
+		return false
+	}
+	cached.epoch = loader.epoch
+	cached.checked = now
+	size, modTime := loader.stat(name)
+	if size != cached.size || !modTime.Equal(cached.modTime) {
+		loader.invalidate(name)
+		return true
+	}
+	for _, dep := range cached.deps {
+		inner, ok := loader.cache[dep]
+		if !ok || loader.stale(dep, inner) {
+			loader.invalidate(name)
+			return true
+		}
+	}
+	return false
+}
+
+// invalidate forgets a cached definition and the definitions of the
+// words that refer to it.
+func (loader *Loader) invalidate(name string) {
+	users := loader.users[name]
+	delete(loader.cache, name)
+	delete(loader.users, name)
+	for user := range users {
+		loader.invalidate(user)
+	}
+}
+
+// stat returns the size and modification time of the file defining
+// a word, or zero values if there is no such file.
+func (loader *Loader) stat(name string) (int64, time.Time) {
+	path := filepath.Join(loader.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, time.Time{}
+	}
+	return info.Size(), info.ModTime()
+}
+
+func (loader *Loader) readFile(name string) (Object, error) {
+	path := filepath.Join(loader.dir, name)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return read(file, path)
+}
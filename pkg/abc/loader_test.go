@@ -0,0 +1,136 @@
+package abc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// define writes the definition of a word into a directory. The file
+// is replaced by renaming, as editors do, so that a loader never sees
+// it half written.
+func define(t *testing.T, dir, name, src string) {
+	t.Helper()
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	err := os.WriteFile(tmp, []byte(src), 0644)
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, name))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Goroutines load and rewrite words while another keeps rewriting the
+// file that they all depend on. Run with -race.
+func TestLoaderConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	versions := []string{"[d]", "[e] [e]"}
+	define(t, dir, "base", versions[0])
+	define(t, dir, "mid", "base base")
+	define(t, dir, "top", "mid [mid] a")
+	loader := NewLoader(dir)
+	loader.Interval = 0
+	done := make(chan struct{})
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				body, err := loader.Load("base")
+				if err != nil {
+					t.Errorf("`base`: %s", err)
+					return
+				}
+				if !Equals(body, MustRead(versions[0])) && !Equals(body, MustRead(versions[1])) {
+					t.Errorf("`base` is `%s`", body)
+					return
+				}
+				_, err = loader.Load("top")
+				if err != nil {
+					t.Errorf("`top`: %s", err)
+					return
+				}
+				config := Config{Quota: 100, Loader: loader, Strategy: Strategy(i % 4)}
+				RewriteWith(MustRead("top"), config)
+			}
+		}(i)
+	}
+	for i := 1; i <= 200; i++ {
+		define(t, dir, "base", versions[i%2])
+		if i%10 == 0 {
+			loader.Refresh()
+		}
+	}
+	close(done)
+	wait.Wait()
+	// The last version differs in size, since a change within the same
+	// tick of the file system's clock is only seen by its size.
+	define(t, dir, "base", "[[b]]")
+	loader.Refresh()
+	got := RewriteWith(MustRead("top"), Config{Quota: 100, Loader: loader})
+	want := MustRead("[[b]] [[b]] [[b]] [[b]]")
+	if !Equals(got, want) {
+		t.Fatalf("after the last change, expected `%s`, but got `%s`", want, got)
+	}
+}
+
+func TestLoaderCycles(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "self", "[self] a")
+	define(t, dir, "ping", "pong")
+	define(t, dir, "pong", "[ping] a")
+	define(t, dir, "user", "[] ping")
+	loader := NewLoader(dir)
+	for _, name := range []string{"self", "ping", "pong", "user"} {
+		_, err := loader.Load(name)
+		if err == nil || !strings.Contains(err.Error(), "contains a cycle") {
+			t.Errorf("`%s`: expected a cycle, but got %v", name, err)
+		}
+	}
+}
+
+// A cycle made by changing a file is found, and forgotten again when
+// the file is changed back.
+func TestLoaderChanges(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "top", "mid")
+	define(t, dir, "mid", "low")
+	define(t, dir, "low", "[]")
+	loader := NewLoader(dir)
+	loader.Interval = 0
+	rewrite := func() Object {
+		return RewriteWith(MustRead("top"), Config{Quota: 100, Loader: loader})
+	}
+	if got := rewrite(); !Equals(got, MustRead("[]")) {
+		t.Fatalf("expected `[]`, but got `%s`", got)
+	}
+	define(t, dir, "low", "top")
+	_, err := loader.Load("top")
+	if err == nil {
+		t.Fatal("expected a cycle after `low` refers to `top`")
+	}
+	define(t, dir, "low", "[] []")
+	if got := rewrite(); !Equals(got, MustRead("[] []")) {
+		t.Fatalf("expected `[] []`, but got `%s`", got)
+	}
+	// With a long interval, changes are only seen after Refresh.
+	loader.Interval = time.Hour
+	define(t, dir, "low", "[[]]")
+	if got := rewrite(); !Equals(got, MustRead("[] []")) {
+		t.Fatalf("expected the cached `[] []`, but got `%s`", got)
+	}
+	loader.Refresh()
+	if got := rewrite(); !Equals(got, MustRead("[[]]")) {
+		t.Fatalf("expected `[[]]` after Refresh, but got `%s`", got)
+	}
+}
@@ -0,0 +1,198 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import ()
+
+// A binder is the sugar `\x y -> body`, which pops values from the
+// stack and names them for the rest of the enclosing block. The last
+// name is bound to the top of the stack. Mentioning a name pushes its
+// value. The reader removes binders by bracket abstraction, so they
+// never appear in an object, but the primitives that replace them
+// have origins that name the variable.
+type binder struct {
+	names []string
+	pos   Pos
+	// at is the index in its block where the binder's body begins.
+	at int
+}
+
+// bind desugars the binders of a block, innermost first, so that a
+// binder's body contains no sugar by the time it is abstracted.
+func bind(build []Object, binders []binder) []Object {
+	for i := len(binders) - 1; i >= 0; i-- {
+		at := binders[i].at
+		body := append([]Object(nil), build[at:]...)
+		for _, name := range binders[i].names {
+			src := &Origin{Pos: binders[i].pos, Local: name}
+			body = abstract(name, src, body)
+		}
+		build = append(build[:at], optimize(body)...)
+	}
+	return build
+}
+
+// flat splits an object into the sequence of objects it concatenates.
+func flat(object Object) []Object {
+	var code []Object
+	for {
+		cat, ok := object.(*mkCat)
+		if !ok {
+			break
+		}
+		code = append(code, cat.fst)
+		object = cat.snd
+	}
+	_, ok := object.(opId)
+	if !ok {
+		code = append(code, object)
+	}
+	return code
+}
+
+// mentions predicates code that pushes a local variable, at any depth.
+func mentions(name string, code []Object) bool {
+	found := false
+	for _, object := range code {
+		Walk(object, func(object Object) bool {
+			local, ok := object.(mkVar)
+			if ok && local.name == name {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// isValue predicates objects that push exactly one value and have no
+// other effect.
+func isValue(object Object) bool {
+	switch object.(type) {
+	case *mkBox:
+		return true
+	default:
+		return false
+	}
+}
+
+// isBound predicates the mention of a local variable bound by an
+// enclosing binder, which the reader marks in its origin. Like a box,
+// it pushes exactly one value, unlike a word, whose definition may do
+// anything.
+func isBound(object Object) bool {
+	local, ok := object.(mkVar)
+	return ok && local.src != nil && local.src.Local != ""
+}
+
+func join(parts ...[]Object) []Object {
+	var code []Object
+	for _, part := range parts {
+		code = append(code, part...)
+	}
+	return code
+}
+
+// abstract removes a local variable from code. The result expects the
+// variable's value on top of the stack, and then behaves as the
+// original code. The primitives it adds have the origin of the
+// mention they stand for, or else src.
+//
+//	\x -> P         = e P           when P doesn't mention x
+//	\x -> x P       = P             when P doesn't mention x
+//	\x -> x P       = d \x -> P
+//	\x -> [Q] P     = b [\x -> Q] c P when P doesn't mention x
+//	\x -> [Q] P     = d b [\x -> Q] c f \x -> P
+//	\x -> V P       = V f \x -> P   when V is a box or a local
+//	\x -> e P       = f e \x -> P
+//	\x -> b P       = f b f \x -> P
+//	\x -> R P       = b [R] f c a \x -> P
+func abstract(name string, src *Origin, code []Object) []Object {
+	a, b, c := opApp{src}, opBox{src}, opCat{src}
+	d, e, f := opCopy{src}, opDrop{src}, opSwap{src}
+	if !mentions(name, code) {
+		return join([]Object{e}, code)
+	}
+	fst, rest := code[0], code[1:]
+	local, isLocal := fst.(mkVar)
+	box, isBox := fst.(*mkBox)
+	_, isDrop := fst.(opDrop)
+	_, isQuote := fst.(opBox)
+	switch {
+	case isLocal && local.name == name:
+		if !mentions(name, rest) {
+			return rest
+		}
+		return join([]Object{opCopy{local.src}}, abstract(name, src, rest))
+	case isBox && mentions(name, []Object{box}):
+		body := abstract(name, src, flat(box.body))
+		inner := &mkBox{newCats(body...), box.src}
+		if !mentions(name, rest) {
+			return join([]Object{b, inner, c}, rest)
+		}
+		head := []Object{d, b, inner, c, f}
+		return join(head, abstract(name, src, rest))
+	case isDrop:
+		return join([]Object{f, fst}, abstract(name, src, rest))
+	case isQuote:
+		return join([]Object{f, fst, f}, abstract(name, src, rest))
+	default:
+		n := 1
+		for !mentions(name, code[n:n+1]) {
+			n++
+		}
+		prefix, rest := code[:n], code[n:]
+		if n == 1 && (isValue(fst) || isBound(fst)) {
+			return join(prefix, []Object{f}, abstract(name, src, rest))
+		}
+		head := []Object{b, &mkBox{newCats(prefix...), src}, f, c, a}
+		return join(head, abstract(name, src, rest))
+	}
+}
+
+// optimize simplifies desugared code with the equations of the
+// primitives; see Equations.
+func optimize(code []Object) []Object {
+	return flat(Optimize(newCats(code...)))
+}
+
+// primitive returns the letter of a primitive, or 0 for any other
+// object.
+func primitive(object Object) byte {
+	switch object.(type) {
+	case opApp:
+		return 'a'
+	case opBox:
+		return 'b'
+	case opCat:
+		return 'c'
+	case opCopy:
+		return 'd'
+	case opDrop:
+		return 'e'
+	case opSwap:
+		return 'f'
+	default:
+		return 0
+	}
+}
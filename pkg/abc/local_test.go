@@ -0,0 +1,56 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocals(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "sw", "f")
+	define(t, dir, "two", "[e] [e]")
+	loader := NewLoader(dir)
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`[b] [c] \x y -> y x`, `[c] [b]`},
+		{`[b] [c] \x y -> x`, `[b]`},
+		{`[b] [c] \x y -> [x y] x`, `[[b] [c]] [b]`},
+		{`[b] \x -> x x x`, `[b] [b] [b]`},
+		{`[b] [c] [d] \x y z -> z x y`, `[d] [b] [c]`},
+		{`[b] [c] \x y -> y [\z -> z x] a`, `[c] [b]`},
+		{`[b] [c] \x -> [\x -> x] x`, `[b] [] [c]`},
+		{`[b] \x -> [x] \y -> y y x`, `[[b]] [[b]] [b]`},
+		{`[b] \foo -> foo foo`, `[b] [b]`},
+		{`[b] [c] \x -> e x`, `[c]`},
+		{`[b] [c] \x -> b e x`, `[c]`},
+		{`[b] [c] [d] \x -> b c x`, `[b [c]] [d]`},
+		// A word is not a value: it runs its definition on the
+		// stack, which may hold more than the values it pushes.
+		{`[b] [c] [d] \x -> sw x`, `[c] [b] [d]`},
+		{`[b] [c] [d] \x -> x sw`, `[b] [d] [c]`},
+		{`[b] [c] \x -> two sw x`, `[b] [e] [e] [c]`},
+		{`[d] [e] [b] [c] \x y -> sw y x`, `[e] [d] [c] [b]`},
+	}
+	for _, test := range cases {
+		object, err := Read(strings.NewReader(test.src))
+		if err != nil {
+			t.Errorf("`%s`: %s", test.src, err)
+			continue
+		}
+		got := RewriteWith(object, Config{Quota: 1000, Loader: loader})
+		if !Equals(got, MustRead(test.want)) {
+			t.Errorf("`%s`, read as `%s`: expected `%s`, but got `%s`", test.src, object, test.want, got)
+		}
+	}
+}
+
+func TestLocalErrors(t *testing.T) {
+	for _, src := range []string{`\x`, `\ -> x`, `\x x -> x`, `\a -> a`, `[\x ] -> x`, `\x -> y`} {
+		_, err := Read(strings.NewReader(src))
+		if err == nil {
+			t.Errorf("`%s`: expected an error", src)
+		}
+	}
+}
@@ -20,23 +20,22 @@ License along with this program.  If not, see
 package abc
 
 import (
-	"fmt"
+	"strings"
 )
 
-type mkBox struct{ body Object }
+type mkBox struct {
+	body Object
+	src  *Origin
+}
 
-func newBox(object Object) Object { return &mkBox{object} }
+func newBox(object Object) Object { return &mkBox{object, nil} }
 func (object *mkBox) String() string {
-	body := object.body.String()
-	return fmt.Sprintf("[%s]", body)
+	var buf strings.Builder
+	show(&buf, object)
+	return buf.String()
 }
 func (lhs *mkBox) eq(rhs Object) bool {
-	switch rhs := rhs.(type) {
-	case *mkBox:
-		return lhs.body.eq(rhs.body)
-	default:
-		return false
-	}
+	return equal(lhs, rhs)
 }
 func (object *mkBox) step(ctx *rewrite) bool {
 	ctx.data.push(object)
@@ -20,7 +20,7 @@ License along with this program.  If not, see
 package abc
 
 import (
-	"fmt"
+	"strings"
 )
 
 type mkCat struct{ fst, snd Object }
@@ -35,13 +35,12 @@ func newCat(fst, snd Object) Object {
 	if ok {
 		return fst
 	}
-	switch fst := fst.(type) {
-	case *mkCat:
-		inner := newCat(fst.snd, snd)
-		return newCat(fst.fst, inner)
-	default:
-		return &mkCat{fst, snd}
+	object := snd
+	code := flat(fst)
+	for i := len(code) - 1; i >= 0; i-- {
+		object = &mkCat{code[i], object}
 	}
+	return object
 }
 
 func newCats(xs ...Object) Object {
@@ -61,29 +60,12 @@ func newCatsR(xs ...Object) Object {
 	return object
 }
 func (object *mkCat) String() string {
-	var ok bool
-	_, ok = object.fst.(opId)
-	if ok {
-		return object.snd.String()
-	}
-	_, ok = object.snd.(opId)
-	if ok {
-		return object.fst.String()
-	}
-	fst := object.fst.String()
-	snd := object.snd.String()
-	return fmt.Sprintf("%s %s", fst, snd)
+	var buf strings.Builder
+	show(&buf, object)
+	return buf.String()
 }
 func (lhs *mkCat) eq(rhs Object) bool {
-	switch rhs := rhs.(type) {
-	case *mkCat:
-		if lhs.fst.eq(rhs.fst) {
-			return lhs.snd.eq(rhs.snd)
-		}
-		return false
-	default:
-		return false
-	}
+	return equal(lhs, rhs)
 }
 func (object *mkCat) step(ctx *rewrite) bool {
 	ctx.work.push(object.snd)
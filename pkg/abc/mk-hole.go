@@ -0,0 +1,41 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import ()
+
+// mkHole is a placeholder for code that isn't known, such as the body
+// of an input to a program being run symbolically. It is written as
+// its name, and gets stuck when it is run.
+type mkHole struct{ name string }
+
+func (object mkHole) String() string { return object.name }
+func (lhs mkHole) eq(rhs Object) bool {
+	switch rhs := rhs.(type) {
+	case mkHole:
+		return lhs.name == rhs.name
+	default:
+		return false
+	}
+}
+func (object mkHole) step(ctx *rewrite) bool {
+	ctx.clear(object, "is a placeholder for unknown code")
+	return false
+}
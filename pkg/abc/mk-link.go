@@ -20,6 +20,7 @@ License along with this program.  If not, see
 package abc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 )
@@ -33,10 +34,14 @@ func (object mkLink) String() string {
 	return fmt.Sprintf("#%s", name)
 }
 func (lhs mkLink) eq(rhs Object) bool {
-	_, ok := rhs.(mkLink)
-	return ok
+	switch rhs := rhs.(type) {
+	case mkLink:
+		return bytes.Equal(lhs.value, rhs.value)
+	default:
+		return false
+	}
 }
 func (object mkLink) step(ctx *rewrite) bool {
-	ctx.clear(object)
+	ctx.clear(object, "links can't be resolved")
 	return false
 }
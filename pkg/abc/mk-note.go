@@ -0,0 +1,49 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mkNote is an annotation, such as `(type [A] -- [A] [A])`. It
+// behaves as the identity function, and vanishes when rewritten.
+type mkNote struct {
+	text string
+	pos  Pos
+}
+
+func newNote(text string, pos Pos) Object {
+	text = strings.Join(strings.Fields(text), " ")
+	return mkNote{text, pos}
+}
+func (object mkNote) String() string {
+	return fmt.Sprintf("(%s)", object.text)
+}
+func (lhs mkNote) eq(rhs Object) bool {
+	switch rhs := rhs.(type) {
+	case mkNote:
+		return lhs.text == rhs.text
+	default:
+		return false
+	}
+}
+func (object mkNote) step(ctx *rewrite) bool { return false }
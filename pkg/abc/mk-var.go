@@ -21,9 +21,12 @@ package abc
 
 import ()
 
-type mkVar struct{ name string }
+type mkVar struct {
+	name string
+	src  *Origin
+}
 
-func newVar(name string) Object { return mkVar{name} }
+func newVar(name string, src *Origin) Object { return mkVar{name, src} }
 func (object mkVar) String() string {
 	return object.name
 }
@@ -36,11 +39,17 @@ func (lhs mkVar) eq(rhs Object) bool {
 	}
 }
 func (object mkVar) step(ctx *rewrite) bool {
-	body, err := readFile(object.name)
+	if ctx.accelerate(object) {
+		return true
+	}
+	body, err := ctx.loader.Load(object.name)
 	if err != nil {
-		ctx.clear(object)
+		ctx.clear(object, "can't be loaded: "+err.Error())
 		return false
 	}
+	if ctx.trace {
+		body = trace(body, object)
+	}
 	ctx.work.push(body)
 	return true
 }
@@ -19,7 +19,10 @@ License along with this program.  If not, see
 
 package abc
 
-import ()
+import (
+	"bufio"
+	"io"
+)
 
 // ABC is a universal combinator calculus, with six primitives:
 //
@@ -44,5 +47,82 @@ type Object interface {
 
 // Equals predicates structurally equivalent objects.
 func Equals(fst, snd Object) bool {
-	return fst.eq(snd)
+	return orEmpty(fst).eq(orEmpty(snd))
+}
+
+// Fprint writes the text of an object to w. Unlike String, it doesn't
+// build the whole text in memory first.
+func Fprint(w io.Writer, object Object) error {
+	buf := bufio.NewWriter(w)
+	show(buf, object)
+	return buf.Flush()
+}
+
+// show writes the text of an object without recursion, so that
+// programs nested arbitrarily deep can be printed. Each task is an
+// object to write, or else punctuation.
+func show(w io.StringWriter, object Object) {
+	type task struct {
+		object Object
+		punct  string
+	}
+	todo := []task{{object: object}}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		switch object := next.object.(type) {
+		case nil:
+			w.WriteString(next.punct)
+		case *mkCat:
+			_, ok := object.fst.(opId)
+			if ok {
+				todo = append(todo, task{object: object.snd})
+				continue
+			}
+			_, ok = object.snd.(opId)
+			if ok {
+				todo = append(todo, task{object: object.fst})
+				continue
+			}
+			todo = append(todo, task{object: object.snd})
+			todo = append(todo, task{punct: " "})
+			todo = append(todo, task{object: object.fst})
+		case *mkBox:
+			w.WriteString("[")
+			todo = append(todo, task{punct: "]"})
+			todo = append(todo, task{object: object.body})
+		default:
+			w.WriteString(object.String())
+		}
+	}
+}
+
+// equal predicates structurally equivalent objects without recursion,
+// comparing boxes and concatenations pairwise.
+func equal(fst, snd Object) bool {
+	todo := [][2]Object{{fst, snd}}
+	for len(todo) > 0 {
+		pair := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		switch lhs := pair[0].(type) {
+		case *mkCat:
+			rhs, ok := pair[1].(*mkCat)
+			if !ok {
+				return false
+			}
+			todo = append(todo, [2]Object{lhs.snd, rhs.snd})
+			todo = append(todo, [2]Object{lhs.fst, rhs.fst})
+		case *mkBox:
+			rhs, ok := pair[1].(*mkBox)
+			if !ok {
+				return false
+			}
+			todo = append(todo, [2]Object{lhs.body, rhs.body})
+		default:
+			if !lhs.eq(pair[1]) {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opApp struct{}
+type opApp struct{ src *Origin }
 
 func (object opApp) String() string { return "a" }
 func (lhs opApp) eq(rhs Object) bool {
@@ -30,12 +30,12 @@ func (lhs opApp) eq(rhs Object) bool {
 }
 func (object opApp) step(ctx *rewrite) bool {
 	if ctx.data.len() == 0 {
-		ctx.clear(object)
+		ctx.clear(object, "needs a box to apply, but the stack is empty")
 		return false
 	}
 	fst, ok := ctx.data.peek(0).(*mkBox)
 	if !ok {
-		ctx.clear(object)
+		ctx.clear(object, notBox(ctx.data.peek(0)))
 		return false
 	}
 	ctx.data.pop()
@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opBox struct{}
+type opBox struct{ src *Origin }
 
 func (object opBox) String() string { return "b" }
 func (lhs opBox) eq(rhs Object) bool {
@@ -30,11 +30,11 @@ func (lhs opBox) eq(rhs Object) bool {
 }
 func (object opBox) step(ctx *rewrite) bool {
 	if ctx.data.len() == 0 {
-		ctx.clear(object)
+		ctx.clear(object, "needs a value to box, but the stack is empty")
 		return false
 	}
 	lhs := ctx.data.pop()
-	rhs := newBox(lhs)
+	rhs := &mkBox{lhs, object.src}
 	ctx.data.push(rhs)
 	return true
 }
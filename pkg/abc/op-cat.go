@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opCat struct{}
+type opCat struct{ src *Origin }
 
 func (object opCat) String() string { return "c" }
 func (lhs opCat) eq(rhs Object) bool {
@@ -30,24 +30,24 @@ func (lhs opCat) eq(rhs Object) bool {
 }
 func (object opCat) step(ctx *rewrite) bool {
 	if ctx.data.len() < 2 {
-		ctx.clear(object)
+		ctx.clear(object, needs(2, "boxes to concatenate", ctx.data.len()))
 		return false
 	}
 	var ok bool
 	rhs, ok := ctx.data.peek(0).(*mkBox)
 	if !ok {
-		ctx.clear(object)
+		ctx.clear(object, notBox(ctx.data.peek(0)))
 		return false
 	}
 	lhs, ok := ctx.data.peek(1).(*mkBox)
 	if !ok {
-		ctx.clear(object)
+		ctx.clear(object, notBox(ctx.data.peek(1)))
 		return false
 	}
 	ctx.data.pop()
 	ctx.data.pop()
 	cat := newCats(lhs.body, rhs.body)
-	box := newBox(cat)
+	box := &mkBox{cat, object.src}
 	ctx.data.push(box)
 	return true
 }
@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opCopy struct{}
+type opCopy struct{ src *Origin }
 
 func (object opCopy) String() string { return "d" }
 func (lhs opCopy) eq(rhs Object) bool {
@@ -30,7 +30,7 @@ func (lhs opCopy) eq(rhs Object) bool {
 }
 func (object opCopy) step(ctx *rewrite) bool {
 	if ctx.data.len() == 0 {
-		ctx.clear(object)
+		ctx.clear(object, "needs a value to copy, but the stack is empty")
 		return false
 	}
 	lhs := ctx.data.peek(0)
@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opDrop struct{}
+type opDrop struct{ src *Origin }
 
 func (object opDrop) String() string { return "e" }
 func (lhs opDrop) eq(rhs Object) bool {
@@ -30,7 +30,7 @@ func (lhs opDrop) eq(rhs Object) bool {
 }
 func (object opDrop) step(ctx *rewrite) bool {
 	if ctx.data.len() == 0 {
-		ctx.clear(object)
+		ctx.clear(object, "needs a value to drop, but the stack is empty")
 		return false
 	}
 	ctx.data.pop()
@@ -21,7 +21,7 @@ package abc
 
 import ()
 
-type opSwap struct{}
+type opSwap struct{ src *Origin }
 
 func (object opSwap) String() string { return "f" }
 func (lhs opSwap) eq(rhs Object) bool {
@@ -30,7 +30,7 @@ func (lhs opSwap) eq(rhs Object) bool {
 }
 func (object opSwap) step(ctx *rewrite) bool {
 	if ctx.data.len() < 2 {
-		ctx.clear(object)
+		ctx.clear(object, needs(2, "values to swap", ctx.data.len()))
 		return false
 	}
 	fst := ctx.data.pop()
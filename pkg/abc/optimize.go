@@ -0,0 +1,365 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+)
+
+// A Rule is an equation between programs, used to simplify code. Its
+// pattern variables are placeholders made by Hole. On the left, each
+// one must be the whole body of a box, which then matches any box;
+// a variable that appears twice matches equal boxes. On the right,
+// a variable stands for the code of the box it matched.
+type Rule struct {
+	Lhs Object
+	Rhs Object
+}
+
+func (rule Rule) String() string {
+	rhs := rule.Rhs.String()
+	if rhs == "" {
+		return fmt.Sprintf("%s =", rule.Lhs)
+	}
+	return fmt.Sprintf("%s = %s", rule.Lhs, rhs)
+}
+
+// check returns an error for a rule that the optimizer can't use.
+// To be sure that optimizing terminates, a rule must remove at least
+// one object other than a box, and must not copy any variable more
+// times than it was matched.
+func (rule Rule) check() error {
+	if len(flat(rule.Lhs)) == 0 {
+		return fmt.Errorf("rule `%s` matches nothing", rule)
+	}
+	lhs, lhsAtoms := variables(rule.Lhs)
+	rhs, rhsAtoms := variables(rule.Rhs)
+	boxed := 0
+	Walk(rule.Lhs, func(object Object) bool {
+		box, ok := object.(*mkBox)
+		if ok {
+			_, ok = box.body.(mkHole)
+		}
+		if ok {
+			boxed++
+		}
+		return true
+	})
+	total := 0
+	for _, count := range lhs {
+		total += count
+	}
+	if boxed != total {
+		msg := "rule `%s` has a variable outside a box on its left"
+		return fmt.Errorf(msg, rule)
+	}
+	for name, count := range rhs {
+		if lhs[name] == 0 {
+			msg := "rule `%s` uses `%s`, which it doesn't match"
+			return fmt.Errorf(msg, rule, name)
+		}
+		if count > lhs[name] {
+			msg := "rule `%s` uses `%s` more often than it matches it"
+			return fmt.Errorf(msg, rule, name)
+		}
+	}
+	if rhsAtoms >= lhsAtoms {
+		return fmt.Errorf("rule `%s` doesn't make code smaller", rule)
+	}
+	return nil
+}
+
+// variables counts the uses of each pattern variable in an object,
+// and the number of other objects in it that aren't boxes.
+func variables(object Object) (map[string]int, int) {
+	vars := make(map[string]int)
+	atoms := 0
+	Walk(object, func(object Object) bool {
+		switch object := object.(type) {
+		case mkHole:
+			vars[object.name]++
+		case *mkBox, *mkCat, opId:
+		default:
+			atoms++
+		}
+		return true
+	})
+	return vars, atoms
+}
+
+// Equations returns the rules that follow from the primitives, each
+// of which makes code smaller:
+//
+//	[A] a     = A
+//	[A] b     = [[A]]
+//	[A] [B] c = [A B]
+//	[A] d e   = [A]
+//	[A] d f   = [A] d
+//	[A] e     =
+//	[A] [B] f = [B] [A]
+//
+// Each is a step that rewriting would take, so they hold on any stack.
+// Code like `d e` or `f f` is left alone: it gets stuck on a stack
+// with too few values, where the empty program does not.
+func Equations() []Rule {
+	a, b, c := opApp{}, opBox{}, opCat{}
+	d, e, f := opCopy{}, opDrop{}, opSwap{}
+	A, B := mkHole{"A"}, mkHole{"B"}
+	boxA, boxB := newBox(A), newBox(B)
+	return []Rule{
+		{newCats(boxA, a), A},
+		{newCats(boxA, b), newBox(boxA)},
+		{newCats(boxA, boxB, c), newBox(newCats(A, B))},
+		{newCats(boxA, d, e), boxA},
+		{newCats(boxA, d, f), newCats(boxA, d)},
+		{newCats(boxA, e), opId{}},
+		{newCats(boxA, boxB, f), newCats(boxB, boxA)},
+	}
+}
+
+// An Optimizer simplifies code by applying rules wherever they match,
+// including inside boxes, until none of them do.
+type Optimizer struct {
+	// Trace, if not nil, is called with each rule the optimizer
+	// applies, and the code that the rule replaced.
+	Trace func(rule Rule, code Object)
+	rules []pattern
+}
+
+// A pattern is a rule prepared for matching.
+type pattern struct {
+	rule Rule
+	lhs  []Object
+	rhs  []Object
+}
+
+// NewOptimizer returns an optimizer for some rules, or an error if
+// one of them could keep the optimizer from terminating.
+func NewOptimizer(rules ...Rule) (*Optimizer, error) {
+	ctx := &Optimizer{}
+	for _, rule := range rules {
+		err := rule.check()
+		if err != nil {
+			return nil, err
+		}
+		prepared := pattern{rule, flat(rule.Lhs), flat(rule.Rhs)}
+		ctx.rules = append(ctx.rules, prepared)
+	}
+	return ctx, nil
+}
+
+var primitives *Optimizer
+
+func init() {
+	var err error
+	primitives, err = NewOptimizer(Equations()...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Optimize simplifies a program using the equations of the
+// primitives, including inside boxes. The result behaves as the
+// program does on any stack, and is never larger.
+func Optimize(object Object) Object {
+	return primitives.Optimize(object)
+}
+
+// Optimize simplifies a program until none of the optimizer's rules
+// apply. Code that a rule keeps keeps its origin, and boxes a rule
+// makes have the origin of the last object it matched.
+func (ctx *Optimizer) Optimize(object Object) Object {
+	object, _ = ctx.optimize(object)
+	return object
+}
+
+// optimize simplifies a program, and also returns whether any rule
+// applied.
+func (ctx *Optimizer) optimize(object Object) (Object, bool) {
+	changed := false
+	for {
+		next, busy := ctx.pass(object)
+		if !busy {
+			return next, changed
+		}
+		object = next
+		changed = true
+	}
+}
+
+// pass simplifies every block of a program once, innermost first,
+// without recursion.
+func (ctx *Optimizer) pass(object Object) (Object, bool) {
+	type frame struct {
+		box   *mkBox
+		parts []Object
+		done  []Object
+	}
+	busy := false
+	stack := []*frame{{parts: flat(object)}}
+	for {
+		next := stack[len(stack)-1]
+		if len(next.done) < len(next.parts) {
+			part := next.parts[len(next.done)]
+			box, ok := part.(*mkBox)
+			if ok {
+				stack = append(stack, &frame{box: box, parts: flat(box.body)})
+			} else {
+				next.done = append(next.done, part)
+			}
+			continue
+		}
+		code, fired := ctx.block(next.done)
+		busy = busy || fired
+		if next.box == nil {
+			return newCats(code...), busy
+		}
+		stack = stack[:len(stack)-1]
+		outer := stack[len(stack)-1]
+		outer.done = append(outer.done, &mkBox{newCats(code...), next.box.src})
+	}
+}
+
+// block simplifies a sequence of code, trying every rule at the end
+// of the code simplified so far after each object is added to it.
+func (ctx *Optimizer) block(code []Object) ([]Object, bool) {
+	var out []Object
+	busy := false
+	todo := make([]Object, len(code))
+	for i, object := range code {
+		todo[len(code)-1-i] = object
+	}
+	for len(todo) > 0 {
+		out = append(out, todo[len(todo)-1])
+		todo = todo[:len(todo)-1]
+		for _, rule := range ctx.rules {
+			n := len(rule.lhs)
+			if n > len(out) {
+				continue
+			}
+			matched := out[len(out)-n:]
+			binds := &bindings{
+				bodies: make(map[string]Object),
+				boxes:  make(map[string]*mkBox),
+			}
+			if !binds.match(rule.lhs, matched) {
+				continue
+			}
+			if ctx.Trace != nil {
+				ctx.Trace(rule.rule, newCats(matched...))
+			}
+			next := binds.instantiate(rule.rhs, matched)
+			out = out[:len(out)-n]
+			for i := len(next) - 1; i >= 0; i-- {
+				todo = append(todo, next[i])
+			}
+			busy = true
+			break
+		}
+	}
+	return out, busy
+}
+
+// bindings are the boxes matched by the variables of a rule.
+type bindings struct {
+	bodies map[string]Object
+	boxes  map[string]*mkBox
+}
+
+func (binds *bindings) match(patterns, code []Object) bool {
+	if len(patterns) != len(code) {
+		return false
+	}
+	for i, pattern := range patterns {
+		lhs, ok := pattern.(*mkBox)
+		if !ok {
+			if !Equals(pattern, code[i]) {
+				return false
+			}
+			continue
+		}
+		rhs, ok := code[i].(*mkBox)
+		if !ok {
+			return false
+		}
+		hole, ok := lhs.body.(mkHole)
+		if !ok {
+			if !binds.match(flat(lhs.body), flat(rhs.body)) {
+				return false
+			}
+			continue
+		}
+		bound, seen := binds.bodies[hole.name]
+		if seen && !Equals(bound, rhs.body) {
+			return false
+		}
+		binds.bodies[hole.name] = rhs.body
+		binds.boxes[hole.name] = rhs
+	}
+	return true
+}
+
+// instantiate returns the code for the right side of a rule. Boxes
+// and primitives that were matched are reused as they are.
+func (binds *bindings) instantiate(rhs, matched []Object) []Object {
+	src := OriginOf(matched[len(matched)-1])
+	var out []Object
+	for _, object := range rhs {
+		switch object.(type) {
+		case mkHole, *mkBox:
+			out = append(out, binds.fill(object, src)...)
+		default:
+			out = append(out, binds.reuse(object, matched, src))
+		}
+	}
+	return out
+}
+
+// fill replaces the variables in part of the right side of a rule.
+// It only recurses as deep as the rule's boxes are nested.
+func (binds *bindings) fill(object Object, src *Origin) []Object {
+	switch object := object.(type) {
+	case mkHole:
+		return flat(binds.bodies[object.name])
+	case *mkBox:
+		hole, ok := object.body.(mkHole)
+		if ok {
+			return []Object{binds.boxes[hole.name]}
+		}
+		var body []Object
+		for _, part := range flat(object.body) {
+			body = append(body, binds.fill(part, src)...)
+		}
+		return []Object{&mkBox{newCats(body...), src}}
+	default:
+		return []Object{object}
+	}
+}
+
+// reuse returns the last matched object equal to one on the right
+// side of a rule, or else that object with the given origin.
+func (binds *bindings) reuse(object Object, matched []Object, src *Origin) Object {
+	for i := len(matched) - 1; i >= 0; i-- {
+		if Equals(object, matched[i]) {
+			return matched[i]
+		}
+	}
+	return located(object, src)
+}
@@ -0,0 +1,78 @@
+package abc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestEquations(t *testing.T) {
+	err := CheckRules(Equations()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"[d] a", "d"},
+		{"[d] b", "[[d]]"},
+		{"[d] [e] c", "[d e]"},
+		{"[d] d e", "[d]"},
+		{"[d] d f", "[d] d"},
+		{"[d] e", ""},
+		{"[d] [e] f", "[e] [d]"},
+		{"[[f] [e] f a] a", "[e] f"},
+		{"[[[] e] [d] d e c]", "[[d]]"},
+		// Code waiting for its inputs is left alone.
+		{"d e", "d e"},
+		{"d f", "d f"},
+		{"f f", "f f"},
+		{"b a", "b a"},
+		{"f e", "f e"},
+		{"[d] f e", "[d] f e"},
+	}
+	for _, test := range cases {
+		got := Optimize(MustRead(test.src))
+		if !Equals(got, MustRead(test.want)) {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+	}
+}
+
+// normalize rewrites a program deeply, and reports whether it finished
+// within the quota.
+func normalize(object Object, quota int) (Object, bool) {
+	left := int64(quota)
+	ctx := &deep{quota: &left, opts: &options{loader: noWords}}
+	object = ctx.rewrite(object, ctx.opts)
+	return object, left >= 0
+}
+
+// Optimizing random programs doesn't change what they rewrite to, on
+// programs that terminate, and never makes them larger.
+func TestOptimizeAgrees(t *testing.T) {
+	const quota = 2000
+	r := rand.New(rand.NewSource(1))
+	checked := 0
+	for i := 0; i < 20000; i++ {
+		src := randomProgram(r, r.Intn(12), 3)
+		object := MustRead(src)
+		optimized := Optimize(object)
+		if len(optimized.String()) > len(object.String()) {
+			t.Fatalf("`%s` grew into `%s`", src, optimized)
+		}
+		want, ok := normalize(object, quota)
+		if !ok {
+			continue
+		}
+		checked++
+		got, ok := normalize(optimized, quota)
+		if !ok || !Equals(got, want) {
+			t.Fatalf("`%s` rewrites to `%s`, but its optimization `%s` rewrites to `%s`",
+				src, want, optimized, got)
+		}
+	}
+	if checked < 1000 {
+		t.Fatalf("only %d programs terminated", checked)
+	}
+}
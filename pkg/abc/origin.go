@@ -0,0 +1,138 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An Origin records where an object came from. Objects made by Read
+// have one, and keep it when they are rewritten, so that the code
+// left in a result can be traced back to its source. Boxes made by
+// `b` and `c` take the origin of the primitive that made them.
+type Origin struct {
+	// Pos is where the object was written.
+	Pos Pos
+	// Local names the local variable whose binding produced the
+	// object, if the object was made by removing one.
+	Local string
+	// Words lists the words that were expanded to reach the object,
+	// outermost first. It is only filled in when rewriting with
+	// Config.Trace.
+	Words []string
+}
+
+func (origin *Origin) String() string {
+	var notes []string
+	if origin.Local != "" {
+		notes = append(notes, fmt.Sprintf("local `%s`", origin.Local))
+	}
+	if len(origin.Words) > 0 {
+		notes = append(notes, "via "+strings.Join(origin.Words, " > "))
+	}
+	if len(notes) == 0 {
+		return origin.Pos.String()
+	}
+	return fmt.Sprintf("%s (%s)", origin.Pos, strings.Join(notes, ", "))
+}
+
+// OriginOf returns where an object came from, or nil if that isn't
+// known. Concatenations have no origin of their own.
+func OriginOf(object Object) *Origin {
+	switch object := object.(type) {
+	case opApp:
+		return object.src
+	case opBox:
+		return object.src
+	case opCat:
+		return object.src
+	case opCopy:
+		return object.src
+	case opDrop:
+		return object.src
+	case opSwap:
+		return object.src
+	case mkVar:
+		return object.src
+	case *mkBox:
+		return object.src
+	case mkNote:
+		if object.pos.Line == 0 {
+			return nil
+		}
+		return &Origin{Pos: object.pos}
+	default:
+		return nil
+	}
+}
+
+// located returns an object with the given origin. Objects that can't
+// have an origin are returned unchanged.
+func located(object Object, src *Origin) Object {
+	switch object := object.(type) {
+	case opApp:
+		return opApp{src}
+	case opBox:
+		return opBox{src}
+	case opCat:
+		return opCat{src}
+	case opCopy:
+		return opCopy{src}
+	case opDrop:
+		return opDrop{src}
+	case opSwap:
+		return opSwap{src}
+	case mkVar:
+		return mkVar{object.name, src}
+	case *mkBox:
+		return &mkBox{object.body, src}
+	default:
+		return object
+	}
+}
+
+// trace copies the definition of a word, adding the word to the
+// origin of everything inside it. The word's own origin says how it
+// was reached.
+func trace(body Object, word mkVar) Object {
+	var words []string
+	if word.src != nil {
+		words = append(words, word.src.Words...)
+	}
+	words = append(words, word.name)
+	return retrace(body, words)
+}
+
+// retrace adds words to the origin of everything in an object,
+// including the bodies of boxes.
+func retrace(object Object, words []string) Object {
+	return Transform(object, func(child Object) Object {
+		src := OriginOf(child)
+		if src == nil {
+			src = &Origin{}
+		} else {
+			next := *src
+			src = &next
+		}
+		src.Words = append(append([]string(nil), words...), src.Words...)
+		return located(child, src)
+	})
+}
@@ -20,99 +20,255 @@ License along with this program.  If not, see
 package abc
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
-var cache map[string]Object
-var cycle map[string]bool
-
-func init() {
-	cache = make(map[string]Object)
-	cycle = make(map[string]bool)
+// A Pos is a position in source text. Lines and columns count from
+// 1, and the file is empty for text that didn't come from a file.
+type Pos struct {
+	File   string
+	Line   int
+	Column int
 }
 
-func readFile(name string) (Object, error) {
-	object, ok := cache[name]
-	if ok {
-		return object, nil
-	}
-	if cycle[name] {
-		msg := "`%s` contains a cycle"
-		err := fmt.Errorf(msg, name)
-		return nil, err
+func (pos Pos) String() string {
+	if pos.File == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
 	}
-	file, err := os.Open(name)
-	if err != nil {
-		return nil, err
+	return fmt.Sprintf("%s:%d:%d", pos.File, pos.Line, pos.Column)
+}
+
+type token struct {
+	text string
+	pos  Pos
+}
+
+// scan splits source text into words, brackets, and annotations,
+// which run from `(` to the next `)`.
+func scan(text, file string) ([]token, error) {
+	var tokens []token
+	var word []rune
+	var start Pos
+	pos := Pos{file, 1, 1}
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, token{string(word), start})
+			word = nil
+		}
 	}
-	cycle[name] = true
-	object, err = Read(file)
-	cycle[name] = false
-	if err != nil {
-		cache[name] = object
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		here := pos
+		if char == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+		switch {
+		case char == '(':
+			flush()
+			note := []rune{char}
+			for char != ')' {
+				i++
+				if i == len(runes) {
+					msg := "%s: Unbalanced annotation"
+					return nil, fmt.Errorf(msg, here)
+				}
+				char = runes[i]
+				if char == '\n' {
+					pos.Line++
+					pos.Column = 1
+				} else {
+					pos.Column++
+				}
+				note = append(note, char)
+			}
+			tokens = append(tokens, token{string(note), here})
+		case char == '[' || char == ']':
+			flush()
+			tokens = append(tokens, token{string(char), here})
+		case unicode.IsSpace(char):
+			flush()
+		default:
+			if len(word) == 0 {
+				start = here
+			}
+			word = append(word, char)
+		}
 	}
-	return object, err
+	flush()
+	return tokens, nil
 }
 
 // Read creates an object from a string. Free variables are resolved
-// using files in the current directory, and cyclic definitions are
-// not allowed.
+// when the object is rewritten, using the files of a Loader, and
+// cyclic definitions are not allowed. Local variables, written
+// `\x y -> body`, are replaced by primitives as they are read. Text
+// that is none of these things, nor a primitive, link or annotation,
+// is an error, reported with its position.
 func Read(src io.Reader) (Object, error) {
+	return read(src, "")
+}
+
+// MustRead is like Read, but panics if the text can't be read. It
+// simplifies the initialization of global variables holding programs,
+// such as those written by GenerateGo.
+func MustRead(src string) Object {
+	object, err := read(strings.NewReader(src), "")
+	if err != nil {
+		panic(err)
+	}
+	return object
+}
+
+// read creates an object from the text of a file, so that positions
+// in the object can name the file.
+func read(src io.Reader, file string) (Object, error) {
 	buf, err := ioutil.ReadAll(src)
 	if err != nil {
 		return nil, err
 	}
-	text := string(buf)
-	text = strings.Replace(text, "[", "[ ", -1)
-	text = strings.Replace(text, "]", " ]", -1)
-	words := strings.Split(text, " ")
-	ident := regexp.MustCompile("^[a-z][a-z0-9-]+$")
+	words, err := scan(string(buf), file)
+	if err != nil {
+		return nil, err
+	}
+	return parse(words, false)
+}
+
+// holeName matches the pattern variables of rules.
+var holeName = regexp.MustCompile("^[A-Z][A-Za-z0-9]*'*$")
+
+// parse creates an object from scanned words. When holes is set, as
+// it is for rules, capitalized words are pattern variables.
+func parse(words []token, holes bool) (Object, error) {
+	local := regexp.MustCompile("^([g-z]|[a-z][a-z0-9-]+)'*$")
 	var build []Object
 	var stack [][]Object
-	for _, word := range words {
+	var opens []Pos
+	var binders []binder
+	var outer [][]binder
+	var scope []string
+	var scopes []int
+	bound := func(name string) bool {
+		for _, other := range scope {
+			if other == name {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < len(words); i++ {
+		word := words[i]
 		switch {
-		case word == "[":
+		case word.text == "[":
 			stack = append(stack, build)
+			opens = append(opens, word.pos)
+			outer = append(outer, binders)
+			scopes = append(scopes, len(scope))
 			build = nil
-		case word == "]":
+			binders = nil
+		case word.text == "]":
 			if len(stack) == 0 {
-				return nil, fmt.Errorf("Unbalanced block")
+				msg := "%s: Unbalanced block"
+				return nil, fmt.Errorf(msg, word.pos)
 			}
-			body := newCats(build...)
-			wrap := newBox(body)
+			body := newCats(bind(build, binders)...)
+			src := &Origin{Pos: opens[len(opens)-1]}
+			wrap := &mkBox{body, src}
 			build = stack[len(stack)-1]
 			build = append(build, wrap)
 			stack = stack[:len(stack)-1]
-		case word == "a":
-			build = append(build, opApp{})
-		case word == "b":
-			build = append(build, opBox{})
-		case word == "c":
-			build = append(build, opCat{})
-		case word == "d":
-			build = append(build, opCopy{})
-		case word == "e":
-			build = append(build, opDrop{})
-		case word == "f":
-			build = append(build, opSwap{})
-		case len(word) == 0:
-			continue
-		case len(word) == 1:
-			msg := "`%s`: words of length 1 are reserved"
-			err := fmt.Errorf(msg, word)
+			opens = opens[:len(opens)-1]
+			binders = outer[len(outer)-1]
+			outer = outer[:len(outer)-1]
+			scope = scope[:scopes[len(scopes)-1]]
+			scopes = scopes[:len(scopes)-1]
+		case word.text[0] == '\\':
+			names := []string{word.text[1:]}
+			if names[0] == "" {
+				names = nil
+			}
+			for {
+				i++
+				if i == len(words) || words[i].text == "[" || words[i].text == "]" {
+					msg := "%s: Expected `->` after `\\`"
+					return nil, fmt.Errorf(msg, word.pos)
+				}
+				if words[i].text == "->" {
+					break
+				}
+				names = append(names, words[i].text)
+			}
+			if len(names) == 0 {
+				msg := "%s: Expected a name after `\\`"
+				return nil, fmt.Errorf(msg, word.pos)
+			}
+			for j, name := range names {
+				if !local.MatchString(name) {
+					msg := "%s: `%s` can't be the name of a local variable"
+					return nil, fmt.Errorf(msg, word.pos, name)
+				}
+				for _, other := range names[:j] {
+					if other == name {
+						msg := "%s: `%s` is bound twice"
+						return nil, fmt.Errorf(msg, word.pos, name)
+					}
+				}
+			}
+			binders = append(binders, binder{names, word.pos, len(build)})
+			scope = append(scope, names...)
+		case word.text == "a":
+			build = append(build, opApp{&Origin{Pos: word.pos}})
+		case word.text == "b":
+			build = append(build, opBox{&Origin{Pos: word.pos}})
+		case word.text == "c":
+			build = append(build, opCat{&Origin{Pos: word.pos}})
+		case word.text == "d":
+			build = append(build, opCopy{&Origin{Pos: word.pos}})
+		case word.text == "e":
+			build = append(build, opDrop{&Origin{Pos: word.pos}})
+		case word.text == "f":
+			build = append(build, opSwap{&Origin{Pos: word.pos}})
+		case word.text[0] == '(':
+			text := word.text[1 : len(word.text)-1]
+			object := newNote(text, word.pos)
+			build = append(build, object)
+		case bound(word.text):
+			src := &Origin{Pos: word.pos, Local: word.text}
+			build = append(build, newVar(word.text, src))
+		case holes && holeName.MatchString(word.text):
+			build = append(build, mkHole{word.text})
+		case len(word.text) == 1:
+			msg := "%s: `%s`: words of length 1 are reserved"
+			err := fmt.Errorf(msg, word.pos, word.text)
 			return nil, err
-		case ident.MatchString(word):
-			object := newVar(word)
+		case word.text[0] == '#':
+			hash, err := hex.DecodeString(word.text[1:])
+			if err != nil || len(hash) == 0 {
+				msg := "%s: `%s` is not a link"
+				return nil, fmt.Errorf(msg, word.pos, word.text)
+			}
+			build = append(build, mkLink{hash})
+		case wordName.MatchString(word.text):
+			object := newVar(word.text, &Origin{Pos: word.pos})
 			build = append(build, object)
+		default:
+			msg := "%s: `%s` is not a word"
+			err := fmt.Errorf(msg, word.pos, word.text)
+			return nil, err
 		}
 	}
 	if len(stack) != 0 {
-		return nil, fmt.Errorf("Unbalanced block")
+		msg := "%s: Unbalanced block"
+		return nil, fmt.Errorf(msg, opens[len(opens)-1])
 	}
-	return newCats(build...), nil
+	return newCats(bind(build, binders)...), nil
 }
@@ -0,0 +1,38 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"f\n FOO", "2:2: `FOO` is not a word"},
+		{"[a] swap!", "1:5: `swap!` is not a word"},
+		{"[a] x", "1:5: `x`: words of length 1 are reserved"},
+		{"[ a\n  [b] c ] ]", "2:11: Unbalanced block"},
+		{"[ a\n  [b] c", "1:1: Unbalanced block"},
+		{"[ a\n  [b (foo] c", "2:6: Unbalanced annotation"},
+		{"#zz", "1:1: `#zz` is not a link"},
+	}
+	for _, test := range cases {
+		_, err := Read(strings.NewReader(test.src))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("`%s`: expected %q, but got %v", test.src, test.want, err)
+		}
+	}
+}
+
+func TestReadWords(t *testing.T) {
+	object, err := Read(strings.NewReader("swap-apply [dup2] (see swap) f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "swap-apply [dup2] (see swap) f"
+	if object.String() != want {
+		t.Fatalf("expected `%s`, but got `%s`", want, object)
+	}
+}
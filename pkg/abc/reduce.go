@@ -19,34 +19,76 @@ License along with this program.  If not, see
 
 package abc
 
+import (
+	"sync/atomic"
+)
+
 // Rewrite rewrites an object until it either reaches a normal
 // form or the effort quota is exhausted.
 func Rewrite(object Object, quota int) Object {
-	ctx := newRewrite(object)
+	budget := int64(quota)
+	return reduce(object, &budget, &options{loader: defaultLoader})
+}
+
+// reduce performs eager reductions on the top level of an object,
+// drawing on a quota that may be shared with other reductions,
+// possibly running on other goroutines.
+func reduce(object Object, quota *int64, opts *options) Object {
+	ctx := newRewrite(object, opts)
 	busy := true
-	for busy && quota > 0 {
+	for busy && atomic.AddInt64(quota, -1) >= 0 {
+		if opts.spec != nil && atomic.AddInt64(opts.spec, -1) < 0 {
+			atomic.StoreInt64(quota, -1)
+			break
+		}
 		busy = ctx.step()
-		quota--
 	}
 	return ctx.Object()
 }
 
+// options are the settings of a reduction, which it shares with the
+// other reductions of the same program.
+type options struct {
+	loader *Loader
+	// trace adds the words that are expanded to the origins of
+	// their definitions.
+	trace bool
+	// report, if not nil, is told about each object that gets stuck.
+	report func(Stuck)
+	// spec, if not nil, is a budget shared by speculative reductions.
+	// Each step draws on it as well as on the quota, and when it runs
+	// out, the quota is treated as having run out too.
+	spec *int64
+}
+
 type rewrite struct {
+	*options
 	kill *stack
 	data *stack
 	work *stack
 }
 
-func newRewrite(init Object) *rewrite {
+func newRewrite(init Object, opts *options) *rewrite {
 	work := newStack()
-	work.push(init)
+	work.push(orEmpty(init))
 	return &rewrite{
-		kill: newStack(),
-		data: newStack(),
-		work: work,
+		kill:    newStack(),
+		data:    newStack(),
+		work:    work,
+		options: opts,
 	}
 }
-func (ctx *rewrite) clear(object Object) {
+
+// clear gives up on an object that can't be rewritten, moving it and
+// everything under it out of the way, and reports why.
+func (ctx *rewrite) clear(object Object, reason string) {
+	if ctx.report != nil {
+		var stack []Object
+		ctx.data.each(func(object Object) {
+			stack = append(stack, object)
+		})
+		ctx.report(Stuck{object, reason, stack, OriginOf(object)})
+	}
 	ctx.data.each(ctx.kill.push)
 	ctx.kill.push(object)
 	ctx.data.clear()
@@ -60,6 +102,9 @@ func (ctx *rewrite) step() bool {
 	}
 	return ctx.work.len() > 0
 }
+
+// Object returns what is left of the program: the code that got
+// stuck, the values on the stack, and the code still to run.
 func (ctx *rewrite) Object() Object {
 	var buf []Object
 	ctx.work.each(func(object Object) {
@@ -68,5 +113,5 @@ func (ctx *rewrite) Object() Object {
 	work := newCatsR(buf...)
 	data := ctx.data.Object()
 	kill := ctx.kill.Object()
-	return newCats(kill, work, data)
+	return newCats(kill, data, work)
 }
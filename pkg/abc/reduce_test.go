@@ -0,0 +1,57 @@
+package abc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// When the quota runs out, the code still to run follows the values
+// on the stack. It used to come before them, so `[b] [c] e [d]` with a
+// quota of 1 gave `[d] [b]`.
+func TestQuotaLeavesWork(t *testing.T) {
+	cases := []struct {
+		src   string
+		quota int
+		want  string
+	}{
+		{"[b] [c] e [d]", 1, "[b] [d]"},
+		{"[d] [e] f f", 1, "[e] [d] f"},
+		{"[a] [b] [c] e [d] e f", 1, "[a] [b] [d] e f"},
+		{"[a] [b] [c] e [d] e f", 2, "[a] [b] f"},
+		{"[[b] e] a [c]", 1, "[b] e [c]"},
+	}
+	for _, test := range cases {
+		config := Config{Quota: test.quota, Loader: noWords}
+		want := MustRead(test.want)
+		got := RewriteWith(MustRead(test.src), config)
+		if !Equals(got, want) {
+			t.Errorf("`%s` with a quota of %d: expected `%s`, but got `%s`", test.src, test.quota, want, got)
+		}
+		got = Compile(MustRead(test.src)).RewriteWith(config)
+		if !Equals(got, want) {
+			t.Errorf("`%s` compiled, with a quota of %d: expected `%s`, but got `%s`", test.src, test.quota, want, got)
+		}
+	}
+}
+
+// Running out of quota only pauses rewriting: rewriting what is left
+// with the rest of the quota gives what the whole quota would have.
+// With the code still to run before the values on the stack, which
+// came from code before it, the program left would be a different one.
+func TestQuotaResumes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		src := randomProgram(r, r.Intn(12), 3)
+		fst, snd := r.Intn(20), r.Intn(20)
+		config := Config{Quota: fst + snd, Loader: noWords}
+		want := RewriteWith(MustRead(src), config)
+		config.Quota = fst
+		paused := RewriteWith(MustRead(src), config)
+		config.Quota = snd
+		got := RewriteWith(paused, config)
+		if !Equals(got, want) {
+			t.Fatalf("`%s`: with a quota of %d, then %d, expected `%s`, but got `%s` by way of `%s`",
+				src, fst, snd, want, got, paused)
+		}
+	}
+}
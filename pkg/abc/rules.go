@@ -0,0 +1,299 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ReadRules reads rules written one to a line as `lhs = rhs`, like
+// the table in Equations. Capitalized words are pattern variables,
+// annotations are ignored, and a box may continue a rule onto the
+// lines that follow. Each rule is checked as NewOptimizer does.
+func ReadRules(src io.Reader) ([]Rule, error) {
+	return readRules(src, "")
+}
+
+// ReadRulesFile reads the rules in a file, so that errors in it name
+// the file.
+func ReadRulesFile(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readRules(file, path)
+}
+
+func readRules(src io.Reader, file string) ([]Rule, error) {
+	buf, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	words, err := scan(string(buf), file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	var line []token
+	var opens []Pos
+	for i, word := range words {
+		switch word.text {
+		case "[":
+			opens = append(opens, word.pos)
+		case "]":
+			if len(opens) > 0 {
+				opens = opens[:len(opens)-1]
+			}
+		}
+		if word.text[0] != '(' {
+			line = append(line, word)
+		}
+		last := i == len(words)-1 || words[i+1].pos.Line != word.pos.Line
+		if !last || len(opens) > 0 || len(line) == 0 {
+			continue
+		}
+		rule, err := readRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+		line = nil
+	}
+	if len(opens) > 0 {
+		msg := "%s: Unbalanced block"
+		return nil, fmt.Errorf(msg, opens[len(opens)-1])
+	}
+	return rules, nil
+}
+
+// readRule reads the words of one rule.
+func readRule(words []token) (Rule, error) {
+	split := -1
+	for i, word := range words {
+		if word.text != "=" {
+			continue
+		}
+		if split >= 0 {
+			msg := "%s: Expected one `=` in a rule"
+			return Rule{}, fmt.Errorf(msg, word.pos)
+		}
+		split = i
+	}
+	if split < 0 {
+		msg := "%s: Expected `=` in a rule"
+		return Rule{}, fmt.Errorf(msg, words[0].pos)
+	}
+	lhs, err := parse(words[:split], true)
+	if err != nil {
+		return Rule{}, err
+	}
+	rhs, err := parse(words[split+1:], true)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{lhs, rhs}
+	err = rule.check()
+	if err != nil {
+		return Rule{}, fmt.Errorf("%s: %s", words[0].pos, err)
+	}
+	return rule, nil
+}
+
+// CheckRules looks for rules that could keep an optimizer from
+// terminating, or that make its result depend on the order in which
+// rules are tried. For the latter, it finds each way in which the
+// left sides of two rules can overlap in a sequence of code, applies
+// either rule, and checks that optimizing both results gives the
+// same code. Overlaps inside the boxes of a rule are not considered,
+// so this is a sanity check rather than a proof of confluence.
+func CheckRules(rules ...Rule) error {
+	ctx, err := NewOptimizer(rules...)
+	if err != nil {
+		return err
+	}
+	for i, fst := range rules {
+		for j, snd := range rules {
+			snd = rename(snd)
+			for _, pair := range overlaps(fst, snd, i == j) {
+				lhs := ctx.Optimize(pair.lhs)
+				rhs := ctx.Optimize(pair.rhs)
+				if !Equals(lhs, rhs) {
+					msg := "rules `%s` and `%s` disagree on `%s`, " +
+						"which becomes `%s` or `%s`"
+					return fmt.Errorf(msg, fst, rules[j], pair.term, lhs, rhs)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// A criticalPair is code matched by two rules at once, and the two
+// results of applying either rule to it.
+type criticalPair struct {
+	term, lhs, rhs Object
+}
+
+// overlaps returns the critical pairs of two rules, whose variables
+// must have different names. When a rule overlaps itself, the trivial
+// overlap of the whole rule is skipped.
+func overlaps(fst, snd Rule, same bool) []criticalPair {
+	var pairs []criticalPair
+	lhs, rhs := flat(fst.Lhs), flat(snd.Lhs)
+	n, m := len(lhs), len(rhs)
+	// The end of the first rule's code is the start of the second's.
+	for k := 1; k <= n && k <= m; k++ {
+		if same && k == n {
+			continue
+		}
+		subst := make(unifier)
+		if !subst.unify(lhs[n-k:], rhs[:k]) {
+			continue
+		}
+		pairs = append(pairs, criticalPair{
+			term: subst.apply(lhs, rhs[k:]),
+			lhs:  subst.apply([]Object{fst.Rhs}, rhs[k:]),
+			rhs:  subst.apply(lhs[:n-k], []Object{snd.Rhs}),
+		})
+	}
+	// The second rule's code is inside the first's.
+	for p := 1; p+m < n; p++ {
+		subst := make(unifier)
+		if !subst.unify(lhs[p:p+m], rhs) {
+			continue
+		}
+		pairs = append(pairs, criticalPair{
+			term: subst.apply(lhs),
+			lhs:  subst.apply([]Object{fst.Rhs}),
+			rhs:  subst.apply(lhs[:p], []Object{snd.Rhs}, lhs[p+m:]),
+		})
+	}
+	return pairs
+}
+
+// rename primes the variables of a rule, to tell them apart from
+// those of another rule.
+func rename(rule Rule) Rule {
+	prime := func(object Object) Object {
+		hole, ok := object.(mkHole)
+		if ok {
+			return mkHole{hole.name + "'"}
+		}
+		return object
+	}
+	return Rule{Transform(rule.Lhs, prime), Transform(rule.Rhs, prime)}
+}
+
+// A unifier maps pattern variables to the bodies of the boxes that
+// they must match.
+type unifier map[string]Object
+
+// resolve returns the variable that a box body is, if it is unbound,
+// after following the variables that it is bound to.
+func (subst unifier) resolve(body Object) (Object, string) {
+	for {
+		hole, ok := body.(mkHole)
+		if !ok {
+			return body, ""
+		}
+		bound, ok := subst[hole.name]
+		if !ok {
+			return body, hole.name
+		}
+		body = bound
+	}
+}
+
+// unify binds variables so that two sequences of patterns match the
+// same code, returning false if they can't.
+func (subst unifier) unify(fst, snd []Object) bool {
+	if len(fst) != len(snd) {
+		return false
+	}
+	for i := range fst {
+		lhs, ok := fst[i].(*mkBox)
+		rhs, isBox := snd[i].(*mkBox)
+		if !ok || !isBox {
+			if ok != isBox || !Equals(fst[i], snd[i]) {
+				return false
+			}
+			continue
+		}
+		lhsBody, lhsHole := subst.resolve(lhs.body)
+		rhsBody, rhsHole := subst.resolve(rhs.body)
+		switch {
+		case lhsHole != "" && lhsHole == rhsHole:
+		case lhsHole != "":
+			if subst.occurs(lhsHole, rhsBody) {
+				return false
+			}
+			subst[lhsHole] = rhsBody
+		case rhsHole != "":
+			if subst.occurs(rhsHole, lhsBody) {
+				return false
+			}
+			subst[rhsHole] = lhsBody
+		default:
+			if !subst.unify(flat(lhsBody), flat(rhsBody)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// occurs predicates a variable that appears in some code, once its
+// bound variables are replaced.
+func (subst unifier) occurs(name string, body Object) bool {
+	found := false
+	Walk(subst.apply([]Object{body}), func(object Object) bool {
+		hole, ok := object.(mkHole)
+		if ok && hole.name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// apply concatenates code, replacing its bound variables.
+func (subst unifier) apply(parts ...[]Object) Object {
+	var code []Object
+	for _, part := range parts {
+		code = append(code, part...)
+	}
+	var fill func(Object) Object
+	fill = func(object Object) Object {
+		hole, ok := object.(mkHole)
+		if !ok {
+			return object
+		}
+		bound, ok := subst[hole.name]
+		if !ok {
+			return object
+		}
+		return Transform(bound, fill)
+	}
+	return Transform(newCats(code...), fill)
+}
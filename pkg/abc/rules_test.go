@@ -0,0 +1,151 @@
+package abc
+
+import (
+	"strings"
+	"testing"
+)
+
+// pairs are the rules for pairs from the README.
+const pairs = `
+(pairs)
+[A] [B] pair fst = [A]
+[A] [B] pair snd = [B]
+`
+
+// mustReadRules reads rules from text, failing the test if it can't.
+func mustReadRules(t *testing.T, src string) []Rule {
+	t.Helper()
+	rules, err := ReadRules(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("`%s`: %s", src, err)
+	}
+	return rules
+}
+
+func TestReadRules(t *testing.T) {
+	rules := mustReadRules(t, pairs+"[[A]\n  [B]] unpair = [A] [B]\n")
+	want := []string{
+		"[A] [B] pair fst = [A]",
+		"[A] [B] pair snd = [B]",
+		"[[A] [B]] unpair = [A] [B]",
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("expected %d rules, but got %v", len(want), rules)
+	}
+	for i, rule := range rules {
+		if rule.String() != want[i] {
+			t.Errorf("expected `%s`, but got `%s`", want[i], rule)
+		}
+	}
+}
+
+// A rule that can't be read, or that the optimizer can't use, is an
+// error that names the line it is on.
+func TestReadRulesErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		line string
+	}{
+		{"[A] [B] pair fst = [A]\n[A] [B] pair snd = [B", "2:"},
+		{"[A] [B] pair fst = [A]\n[A] [B] pair snd", "2:"},
+		{"[A] a = A = A", "1:"},
+		{"[A] = [A] [A]", "1:"},
+		{"\n[A] d = [A] [A]", "2:"},
+		{"[A] e = [B]", "1:"},
+		{"[A]] e =", "1:"},
+		{"[A] Swap =", "1:"},
+	}
+	for _, test := range cases {
+		_, err := ReadRules(strings.NewReader(test.src))
+		if err == nil {
+			t.Errorf("`%s`: expected an error", test.src)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), test.line) {
+			t.Errorf("`%s`: expected an error on line %s but got: %s", test.src, test.line, err)
+		}
+	}
+}
+
+// Rules that overlap must agree on the code they both match.
+func TestCheckRules(t *testing.T) {
+	cases := []struct {
+		src   string
+		agree bool
+	}{
+		{pairs, true},
+		// `pp qq ss` becomes `rr ss` and then `pp`, or `pp` at once.
+		{"pp qq = rr\nqq ss =\nrr ss = pp", true},
+		// `pp qq ss` becomes `rr ss` or `pp tt`.
+		{"pp qq = rr\nqq ss = tt", false},
+		// A rule may overlap itself: `[A] [A] [A] dup2` matches it at
+		// either end.
+		{"[A] [A] dup2 = [A]", true},
+		{"[A] [B] f = [B] [A]\n[A] f =", false},
+		// `[A] d e` is inside the left side of the other rule.
+		{"[A] d e = [A]\n[A] d e f = f", false},
+		{"[A] d e = [A]\n[A] d e f = [A] f", true},
+	}
+	for _, test := range cases {
+		err := CheckRules(mustReadRules(t, test.src)...)
+		if (err == nil) != test.agree {
+			t.Errorf("`%s`: expected the rules to agree to be %v, but got %v", test.src, test.agree, err)
+		}
+	}
+	err := CheckRules(Rule{MustRead("[b] e"), MustRead("[b] e")})
+	if err == nil {
+		t.Error("expected an error for a rule that doesn't make code smaller")
+	}
+}
+
+// Rules given to rewriting simplify its result, which is rewritten
+// again until the rules no longer apply.
+func TestConfigRules(t *testing.T) {
+	ctx, err := NewOptimizer(mustReadRules(t, pairs)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"[b] [c] pair fst", "[b]"},
+		{"[b] [c] pair fst [d] [e] pair snd", "[b] [e]"},
+		{"[c] [[b] b] pair snd a", "[[b]]"},
+		{"[[b] [c] pair snd]", "[[c]]"},
+		{"[b] pair fst", "[b] pair fst"},
+	}
+	for _, test := range cases {
+		var stuck []string
+		config := Config{Quota: 1000, Loader: noWords, Rules: ctx}
+		config.Stuck = func(s Stuck) { stuck = append(stuck, s.Object.String()) }
+		got := RewriteWith(MustRead(test.src), config)
+		if !Equals(got, MustRead(test.want)) {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+		if (len(stuck) > 0) != strings.Contains(test.want, "pair") {
+			t.Errorf("`%s`: expected only `pair` to be reported stuck, but got %q", test.src, stuck)
+		}
+	}
+}
+
+// An optimizer with a trace is told about each rule it applies, and
+// the code the rule matched, including inside boxes.
+func TestOptimizerTrace(t *testing.T) {
+	ctx, err := NewOptimizer(mustReadRules(t, pairs)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	ctx.Trace = func(rule Rule, code Object) {
+		got = append(got, rule.String()+": "+code.String())
+	}
+	ctx.Optimize(MustRead("[b] [c] pair snd [[d] [e] pair fst] f"))
+	want := []string{
+		"[A] [B] pair fst = [A]: [d] [e] pair fst",
+		"[A] [B] pair snd = [B]: [b] [c] pair snd",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the trace\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,73 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import ()
+
+// A Specializer makes versions of words for arguments that are known
+// ahead of time, such as the boxes that a program always passes to a
+// generic word.
+type Specializer struct {
+	// Quota bounds the effort spent rewriting each specialization.
+	Quota int
+	// Loader resolves words. If it is nil, words are read from files
+	// in the current directory.
+	Loader *Loader
+	// Store, if not nil, keeps each specialization, so that it can
+	// be linked to by its hash; see HashOf.
+	Store *Store
+}
+
+// defaultSpecializeQuota is the quota used by Specialize.
+const defaultSpecializeQuota = 10000
+
+// Specialize returns code that does what pushing some arguments and
+// then calling a word does, with the word's definition inlined and
+// rewritten as far as possible, including inside boxes.
+func Specialize(word string, args ...Object) (Object, error) {
+	ctx := Specializer{Quota: defaultSpecializeQuota}
+	return ctx.Specialize(word, args...)
+}
+
+// Specialize returns code that does what pushing some arguments and
+// then calling a word does. The word's definition is inlined, along
+// with the words it uses, and the result is rewritten under boxes
+// and then optimized. If that doesn't make it any smaller than the
+// arguments followed by the definition, the latter is returned.
+func (ctx Specializer) Specialize(word string, args ...Object) (Object, error) {
+	loader := ctx.Loader
+	if loader == nil {
+		loader = defaultLoader
+	}
+	body, err := loader.Load(word)
+	if err != nil {
+		return nil, err
+	}
+	code := newCats(append(append([]Object(nil), args...), body)...)
+	config := Config{Quota: ctx.Quota, Strategy: Deep, Loader: loader}
+	object := Optimize(RewriteWith(code, config))
+	if len(object.String()) >= len(code.String()) {
+		object = code
+	}
+	if ctx.Store != nil {
+		ctx.Store.Put(object)
+	}
+	return object, nil
+}
@@ -0,0 +1,44 @@
+package abc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSpecialize(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "twice", "d c")
+	define(t, dir, "swap-apply", "f a")
+	store := NewStore()
+	ctx := Specializer{Quota: 1000, Loader: NewLoader(dir), Store: store}
+	cases := []struct {
+		word string
+		args string
+		want string
+	}{
+		{"twice", "[b]", "[b b]"},
+		{"swap-apply", "[d] [[e] a]", "[e] [e]"},
+		// Nothing is gained by specializing to no arguments.
+		{"swap-apply", "", "f a"},
+	}
+	for _, test := range cases {
+		object, err := ctx.Specialize(test.word, Parts(MustRead(test.args))...)
+		if err != nil {
+			t.Fatalf("`%s`: %s", test.word, err)
+		}
+		if !Equals(object, MustRead(test.want)) {
+			t.Errorf("`%s %s`: expected `%s`, but got `%s`", test.args, test.word, test.want, object)
+		}
+		stored, ok := store.Get(HashOf(object))
+		if !ok || !Equals(stored, object) {
+			t.Errorf("`%s %s`: `%s` isn't in the store", test.args, test.word, object)
+		}
+	}
+	if !bytes.Equal(HashOf(MustRead("[d d]")), Hash(store.Put(MustRead("[d d]")))) {
+		t.Errorf("HashOf isn't the hash of a link made by Put")
+	}
+	_, err := ctx.Specialize("missing")
+	if err == nil {
+		t.Errorf("expected an error for a missing word")
+	}
+}
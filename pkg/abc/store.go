@@ -0,0 +1,91 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// A Store holds objects by the SHA-256 hash of their text, so that
+// links to them can be resolved. A Store may be used by multiple
+// goroutines at once.
+type Store struct {
+	lock    sync.Mutex
+	objects map[string]Object
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{objects: make(map[string]Object)}
+}
+
+// HashOf returns the hash by which a store holds an object, which is
+// the SHA-256 hash of its text.
+func HashOf(object Object) []byte {
+	sum := sha256.Sum256([]byte(orEmpty(object).String()))
+	return sum[:]
+}
+
+// Put adds an object to the store, and returns a link to it.
+func (store *Store) Put(object Object) Object {
+	hash := HashOf(object)
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.objects[hex.EncodeToString(hash)] = object
+	return mkLink{hash}
+}
+
+// Get returns the object with the given hash, if the store has it.
+func (store *Store) Get(hash []byte) (Object, bool) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	object, ok := store.objects[hex.EncodeToString(hash)]
+	return object, ok
+}
+
+// Resolve replaces the links in an object with the objects they refer
+// to, as far as the store has them. Links that the store doesn't have
+// are left alone.
+func (store *Store) Resolve(object Object) Object {
+	return Transform(object, func(object Object) Object {
+		link, ok := object.(mkLink)
+		if !ok {
+			return object
+		}
+		target, ok := store.Get(link.value)
+		if !ok {
+			return object
+		}
+		return store.Resolve(target)
+	})
+}
+
+// EqualsIn predicates objects that are structurally equivalent once
+// their links are resolved through a store, so that a program with a
+// link is equal to the same program with the linked object inline.
+// Without a store, it is the same as Equals.
+func EqualsIn(fst, snd Object, store *Store) bool {
+	if store == nil {
+		return Equals(fst, snd)
+	}
+	return Equals(store.Resolve(fst), store.Resolve(snd))
+}
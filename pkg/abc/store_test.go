@@ -0,0 +1,70 @@
+package abc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestLinkEquality(t *testing.T) {
+	fst := MustRead("#aaaa")
+	if !Equals(fst, MustRead("#aaaa")) {
+		t.Errorf("`#aaaa` isn't equal to itself")
+	}
+	if Equals(fst, MustRead("#bbbb")) {
+		t.Errorf("`#aaaa` is equal to `#bbbb`")
+	}
+	if Equals(MustRead("[#aaaa] d"), MustRead("[#aaaa00] d")) {
+		t.Errorf("links of different lengths are equal")
+	}
+}
+
+// Store round trips: a program is put in a store, and linked to from
+// other programs, inline or inside boxes, which resolve back to the
+// program with the linked code in place.
+func TestStore(t *testing.T) {
+	store := NewStore()
+	swap := MustRead("[f] a")
+	link := store.Put(swap)
+	sum := sha256.Sum256([]byte(swap.String()))
+	if link.String() != "#"+hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected a link to the hash of `%s`, but got `%s`", swap, link)
+	}
+	got, ok := store.Get(Hash(link))
+	if !ok || !Equals(got, swap) {
+		t.Fatalf("expected to get `%s` back, but got `%s`", swap, got)
+	}
+	if _, ok := store.Get(Hash(MustRead("#aaaa"))); ok {
+		t.Fatalf("got an object that was never put")
+	}
+	// A link to code that itself holds a link.
+	outer := store.Put(Cat(Box(link), MustRead("d"), link))
+	mixed := MustRead(strings.Join([]string{"[e]", link.String(), "[" + outer.String() + "]"}, " "))
+	inline := MustRead("[e] [f] a [[[f] a] d [f] a]")
+	resolved := store.Resolve(mixed)
+	if !Equals(resolved, inline) {
+		t.Fatalf("expected `%s`, but got `%s`", inline, resolved)
+	}
+	if Equals(mixed, inline) {
+		t.Errorf("`%s` is equal to `%s` without a store", mixed, inline)
+	}
+	if !EqualsIn(mixed, inline, store) || !EqualsIn(inline, mixed, store) {
+		t.Errorf("`%s` isn't equal to `%s` in the store", mixed, inline)
+	}
+	if !EqualsIn(mixed, mixed, nil) || EqualsIn(mixed, inline, nil) {
+		t.Errorf("EqualsIn without a store isn't Equals")
+	}
+	if EqualsIn(mixed, MustRead("[e] [f] a [[[f] a] d]"), store) {
+		t.Errorf("`%s` is equal to a different program in the store", mixed)
+	}
+	// Links that the store doesn't have are left alone, and compared
+	// by their hashes.
+	unknown := MustRead("[#aaaa] " + link.String())
+	if got := store.Resolve(unknown); !Equals(got, MustRead("[#aaaa] [f] a")) {
+		t.Errorf("expected `[#aaaa] [f] a`, but got `%s`", got)
+	}
+	if EqualsIn(unknown, MustRead("[#bbbb] [f] a"), store) {
+		t.Errorf("different unknown links are equal in the store")
+	}
+}
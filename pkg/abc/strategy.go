@@ -0,0 +1,264 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Strategy selects the order in which reductions are performed.
+// Every strategy uses the same primitive rules; they differ only in
+// where they look for work.
+type Strategy int
+
+const (
+	// Eager reduces a program from left to right on a stack
+	// machine, and never looks inside boxes.
+	Eager Strategy = iota
+	// Lazy rewrites under boxes like Deep, but remembers the rewrite
+	// of each box it visits. No strategy copies code for `d`: its
+	// copies are the same box. What Lazy saves is rewriting each
+	// copy's body again, so a copied box is only rewritten once, and
+	// the copies in the result share that rewrite. A box made from a
+	// copy, such as by `c`, is a new box, and is rewritten again.
+	Lazy
+	// Deep reduces a program like Eager, then rewrites the bodies
+	// of the boxes left in the result.
+	Deep
+	// Parallel rewrites under boxes like Deep, but the bodies of
+	// the boxes left in a result are rewritten concurrently. The
+	// result is always the same as Deep, but when the quota runs out,
+	// Parallel may have done up to twice the work to reach it. Only
+	// the boxes at the top level of a result are rewritten at once;
+	// the boxes inside them are rewritten in sequence.
+	Parallel
+)
+
+// Config controls how RewriteWith reduces a program.
+type Config struct {
+	// Quota bounds the effort spent on the whole program,
+	// including any work done under boxes.
+	Quota int
+	// Strategy selects the order in which reductions happen.
+	Strategy Strategy
+	// Workers bounds the number of goroutines used by the Parallel
+	// strategy. If it is zero, GOMAXPROCS is used.
+	Workers int
+	// Loader resolves the words in a program. If it is nil, words
+	// are read from files in the current directory.
+	Loader *Loader
+	// Trace records, in the origin of each object, the words that
+	// were expanded to reach it. This copies every definition as it
+	// is expanded, so it is off by default.
+	Trace bool
+	// Stuck, if not nil, is called for each object at the top level
+	// of the program that can't be rewritten. The bodies of boxes
+	// are often stuck waiting for their inputs, so they aren't
+	// reported.
+	Stuck func(Stuck)
+	// Rules, if not nil, simplifies the result whenever rewriting
+	// stops, after which rewriting continues until neither makes
+	// progress or the quota runs out.
+	Rules *Optimizer
+}
+
+// RewriteWith rewrites an object using the given configuration.
+func RewriteWith(object Object, config Config) Object {
+	object = orEmpty(object)
+	quota := int64(config.Quota)
+	loader := config.Loader
+	if loader == nil {
+		loader = defaultLoader
+	}
+	opts := &options{loader: loader, trace: config.Trace}
+	top := &options{loader: loader, trace: config.Trace, report: config.Stuck}
+	ctx := &deep{quota: &quota, opts: opts}
+	switch config.Strategy {
+	case Lazy:
+		ctx.share = make(map[*mkBox]Object)
+	case Parallel:
+		workers := config.Workers
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		ctx.pool = make(chan struct{}, workers)
+	}
+	if config.Rules == nil {
+		return ctx.run(object, config.Strategy, top)
+	}
+	for {
+		object = ctx.run(object, config.Strategy, opts)
+		if quota < 0 {
+			return object
+		}
+		next, changed := config.Rules.optimize(object)
+		if !changed {
+			break
+		}
+		object = next
+	}
+	if config.Stuck == nil {
+		return object
+	}
+	// Run the result once more, only to report what is stuck in it.
+	return reduce(object, &quota, top)
+}
+
+// run rewrites an object once with a strategy.
+func (ctx *deep) run(object Object, strategy Strategy, top *options) Object {
+	switch strategy {
+	case Lazy, Deep, Parallel:
+		return ctx.rewrite(object, top)
+	default:
+		return reduce(object, ctx.quota, top)
+	}
+}
+
+// deep rewrites the top level of a program, and then the bodies of
+// its boxes. When share is non-nil, it remembers the rewrite of each
+// box so that copies of that box are not rewritten again. When pool
+// is non-nil, its capacity is the number of extra goroutines that
+// may rewrite boxes at once. The bodies of boxes are rewritten with
+// opts.
+type deep struct {
+	quota *int64
+	opts  *options
+	share map[*mkBox]Object
+	pool  chan struct{}
+}
+
+func (ctx *deep) rewrite(object Object, opts *options) Object {
+	object = reduce(object, ctx.quota, opts)
+	var buf []Object
+	for {
+		cat, ok := object.(*mkCat)
+		if !ok {
+			break
+		}
+		buf = append(buf, cat.fst)
+		object = cat.snd
+	}
+	buf = append(buf, object)
+	if ctx.pool != nil {
+		ctx.spread(buf)
+	} else {
+		for i, child := range buf {
+			buf[i] = ctx.visit(child)
+		}
+	}
+	return newCats(buf...)
+}
+
+// spread visits each object in place, trying boxes concurrently while
+// the pool has room, and on the current goroutine otherwise. Each box
+// is tried with a quota of its own, holding all that is left, but
+// every step of every trial also draws on one budget, shared by all
+// of them, that holds no more than that, so speculating never costs
+// more than the quota itself. A trial that exhausts either is given
+// up. Then, in order, a box's result is kept if it finished within
+// what the boxes before it left over, as it would have in sequence.
+// The first box that didn't, and so every box after it, is visited
+// again in sequence, so the result is always the one that Deep gives.
+// Trials, and the boxes visited again, rewrite the boxes inside them
+// in sequence, so that only one level of a program speculates.
+func (ctx *deep) spread(buf []Object) {
+	type trial struct {
+		object Object
+		left   int64
+	}
+	start := atomic.LoadInt64(ctx.quota)
+	seq := &deep{quota: ctx.quota, opts: ctx.opts}
+	count := 0
+	for _, child := range buf {
+		_, ok := child.(*mkBox)
+		if ok {
+			count++
+		}
+	}
+	if count < 2 || start <= 0 {
+		for i, child := range buf {
+			buf[i] = seq.visit(child)
+		}
+		return
+	}
+	budget := start
+	opts := *ctx.opts
+	opts.spec = &budget
+	trials := make([]trial, len(buf))
+	try := func(i int, child Object) {
+		left := start
+		sub := &deep{quota: &left, opts: &opts}
+		object := sub.visit(child)
+		trials[i] = trial{object, atomic.LoadInt64(&left)}
+	}
+	var wait sync.WaitGroup
+	for i, child := range buf {
+		_, ok := child.(*mkBox)
+		if !ok {
+			continue
+		}
+		select {
+		case ctx.pool <- struct{}{}:
+			wait.Add(1)
+			go func(i int, child Object) {
+				defer wait.Done()
+				try(i, child)
+				<-ctx.pool
+			}(i, child)
+		default:
+			try(i, child)
+		}
+	}
+	wait.Wait()
+	for i, child := range buf {
+		_, ok := child.(*mkBox)
+		if !ok {
+			continue
+		}
+		next := trials[i]
+		used := start - next.left
+		if next.object != nil && next.left >= 0 && used <= atomic.LoadInt64(ctx.quota) {
+			buf[i] = next.object
+			atomic.AddInt64(ctx.quota, -used)
+			continue
+		}
+		buf[i] = seq.visit(child)
+	}
+}
+
+func (ctx *deep) visit(object Object) Object {
+	box, ok := object.(*mkBox)
+	if !ok {
+		return object
+	}
+	if ctx.share != nil {
+		next, ok := ctx.share[box]
+		if ok {
+			return next
+		}
+	}
+	next := &mkBox{ctx.rewrite(box.body, ctx.opts), box.src}
+	if ctx.share != nil {
+		ctx.share[box] = next
+	}
+	return next
+}
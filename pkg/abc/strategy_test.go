@@ -0,0 +1,164 @@
+package abc
+
+import (
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// On programs that terminate, Lazy gives the same result as Deep,
+// and Deep does what Eager does before rewriting the bodies of boxes.
+func TestStrategiesAgree(t *testing.T) {
+	const quota = 2000
+	r := rand.New(rand.NewSource(1))
+	checked := 0
+	for i := 0; i < 5000; i++ {
+		src := randomProgram(r, r.Intn(10), 3)
+		object := MustRead(src)
+		if !settles(object, Deep, quota) {
+			continue
+		}
+		checked++
+		config := Config{Quota: quota, Loader: noWords}
+		config.Strategy = Deep
+		deep := RewriteWith(object, config)
+		config.Strategy = Lazy
+		lazy := RewriteWith(object, config)
+		if !Equals(deep, lazy) {
+			t.Fatalf("`%s`: Deep gives `%s`, but Lazy gives `%s`", src, deep, lazy)
+		}
+		config.Strategy = Eager
+		eager := RewriteWith(object, config)
+		config.Strategy = Deep
+		both := RewriteWith(eager, config)
+		if !Equals(deep, both) {
+			t.Fatalf("`%s`: Deep gives `%s`, but Eager then Deep gives `%s`", src, deep, both)
+		}
+	}
+	if checked < 1000 {
+		t.Fatalf("only %d programs terminated", checked)
+	}
+}
+
+func TestStrategyExamples(t *testing.T) {
+	cases := []struct {
+		src      string
+		strategy Strategy
+		want     string
+	}{
+		{"[[d] [e] f] a", Eager, "[e] [d]"},
+		{"[[d] [e] f]", Eager, "[[d] [e] f]"},
+		{"[[d] [e] f]", Deep, "[[e] [d]]"},
+		{"[[d] [e] f]", Lazy, "[[e] [d]]"},
+		{"[[[] b] a] d", Lazy, "[[[]]] [[[]]]"},
+	}
+	for _, test := range cases {
+		config := Config{Quota: 100, Strategy: test.strategy, Loader: noWords}
+		got := RewriteWith(MustRead(test.src), config)
+		if !Equals(got, MustRead(test.want)) {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+	}
+}
+
+// Parallel gives exactly what Deep gives, whatever the quota, even
+// when the quota runs out part of the way through the boxes.
+func TestParallelMatchesDeep(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 3000; i++ {
+		src := randomProgram(r, r.Intn(10), 3)
+		object := MustRead(src)
+		quota := r.Intn(60)
+		config := Config{Quota: quota, Strategy: Deep, Loader: noWords}
+		deep := RewriteWith(object, config)
+		config.Strategy = Parallel
+		config.Workers = 1 + r.Intn(4)
+		parallel := RewriteWith(object, config)
+		if !Equals(deep, parallel) {
+			msg := "`%s` with a quota of %d: Deep gives `%s`, but Parallel gives `%s`"
+			t.Fatalf(msg, src, quota, deep, parallel)
+		}
+	}
+}
+
+func TestParallelDeterministic(t *testing.T) {
+	var parts []Object
+	for i := 0; i < 16; i++ {
+		parts = append(parts, MustRead("[[[d] [e] f f] a [[b] [c] f] a d]"))
+	}
+	object := Cat(parts...)
+	config := Config{Quota: 60, Strategy: Deep, Loader: noWords}
+	deep := RewriteWith(object, config)
+	config.Strategy = Parallel
+	config.Workers = 8
+	for i := 0; i < 200; i++ {
+		parallel := RewriteWith(object, config)
+		if !Equals(deep, parallel) {
+			t.Fatalf("run %d: Deep gives `%s`, but Parallel gives `%s`", i, deep, parallel)
+		}
+	}
+}
+
+// Lazy rewrites the body of a box copied by `d` once, where Deep
+// rewrites it for every copy, so Lazy finishes with far less quota.
+func TestLazySharesCopies(t *testing.T) {
+	const src = "[[] b b b b b b b b b b] d d d d d d d"
+	config := Config{Quota: 28, Strategy: Lazy, Loader: noWords}
+	lazy := RewriteWith(MustRead(src), config)
+	config.Quota = 1000
+	config.Strategy = Deep
+	want := RewriteWith(MustRead(src), config)
+	if !Equals(lazy, want) {
+		t.Fatalf("with a quota of 28, expected Lazy to give `%s`, but got `%s`", want, lazy)
+	}
+	parts := Parts(lazy)
+	for _, part := range parts[1:] {
+		if part != parts[0] {
+			t.Fatalf("the copies in `%s` don't share their rewrite", lazy)
+		}
+	}
+	config.Quota = 28
+	deep := RewriteWith(MustRead(src), config)
+	if Equals(deep, want) {
+		t.Fatalf("expected Deep to run out of quota, but it gave `%s`", deep)
+	}
+}
+
+// Speculating on boxes that don't finish costs Parallel no more than
+// the quota again, however many there are and however deeply they are
+// nested. The loops count their turns with an accelerated word.
+func TestParallelWaste(t *testing.T) {
+	dir := t.TempDir()
+	define(t, dir, "tick", "")
+	loader := NewLoader(dir)
+	var ticks int64
+	loader.Accelerate("tick", func(stack []Object) ([]Object, Object, bool) {
+		atomic.AddInt64(&ticks, 1)
+		return stack, nil, true
+	})
+	loop := "[[tick d a] d a]"
+	nest := func(body string) string {
+		return strings.TrimSpace(strings.Repeat(body+" ", 4))
+	}
+	cases := []string{
+		strings.Repeat(loop+" ", 8),
+		nest("[" + nest("["+nest(loop)+"]") + "]"),
+	}
+	for i, src := range cases {
+		count := func(strategy Strategy, workers int) int64 {
+			atomic.StoreInt64(&ticks, 0)
+			config := Config{Quota: 3000, Strategy: strategy, Workers: workers, Loader: loader}
+			RewriteWith(MustRead(src), config)
+			return atomic.LoadInt64(&ticks)
+		}
+		deep := count(Deep, 0)
+		for _, workers := range []int{1, 4} {
+			parallel := count(Parallel, workers)
+			if parallel > 2*deep+1 {
+				t.Errorf("case %d with %d workers: Deep takes %d turns, but Parallel takes %d",
+					i, workers, deep, parallel)
+			}
+		}
+	}
+}
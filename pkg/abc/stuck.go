@@ -0,0 +1,57 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+)
+
+// A Stuck reports an object that could not be rewritten, which is
+// why it is left in the result along with everything before it.
+type Stuck struct {
+	// Object is the primitive, word or link that got stuck.
+	Object Object
+	// Reason says what the object needed, and what it found.
+	Reason string
+	// Stack holds the values that were on the stack, bottom first.
+	Stack []Object
+	// Origin is where the object came from, if that is known.
+	Origin *Origin
+}
+
+func (stuck Stuck) String() string {
+	msg := fmt.Sprintf("`%s` %s", stuck.Object, stuck.Reason)
+	if stuck.Origin == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", stuck.Origin, msg)
+}
+
+func needs(count int, what string, have int) string {
+	if have == 0 {
+		return fmt.Sprintf("needs %d %s, but the stack is empty", count, what)
+	}
+	msg := "needs %d %s, but the stack has only %d"
+	return fmt.Sprintf(msg, count, what, have)
+}
+
+func notBox(object Object) string {
+	return fmt.Sprintf("needs a box, but found `%s`", object)
+}
@@ -0,0 +1,103 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hole returns a placeholder for unknown code, written as its name.
+// A placeholder gets stuck when it is run, so a program run on boxed
+// placeholders shows what it does in terms of its inputs.
+func Hole(name string) Object { return mkHole{name} }
+
+// A Behavior is what a program does to a stack of unknown boxes: the
+// boxes it was given, and the result of rewriting it on top of them.
+type Behavior struct {
+	Inputs []Object
+	Result Object
+}
+
+// String writes a behavior like the primitive rules, such as
+// `[X] [Y] -> [Y] [X]` for `f`.
+func (behavior Behavior) String() string {
+	var ins []string
+	for _, input := range behavior.Inputs {
+		ins = append(ins, input.String())
+	}
+	lhs := strings.Join(ins, " ")
+	rhs := behavior.Result.String()
+	if rhs == "" {
+		return strings.TrimSpace(lhs + " ->")
+	}
+	if lhs == "" {
+		return "-> " + rhs
+	}
+	return lhs + " -> " + rhs
+}
+
+// maxInputs bounds the number of inputs Symbolic tries, when it
+// can't infer how many a program takes.
+const maxInputs = 8
+
+// Symbolic runs a program on boxed placeholders named `X`, `Y`, `Z`,
+// `A`, `B` and so on, from the bottom of the stack up. The number of
+// inputs is the one given by the program's inferred effect, or else
+// the fewest on which the program doesn't get stuck.
+func Symbolic(object Object, config Config) Behavior {
+	loader := config.Loader
+	if loader == nil {
+		loader = defaultLoader
+	}
+	count := 0
+	effect, err := loader.Infer(object)
+	if err == nil {
+		count = effect.Inputs()
+	} else {
+		for n := 0; n <= maxInputs; n++ {
+			stuck := false
+			trial := config
+			trial.Stuck = func(Stuck) { stuck = true }
+			RewriteWith(newCats(append(holes(n), object)...), trial)
+			if !stuck {
+				count = n
+				break
+			}
+		}
+	}
+	inputs := holes(count)
+	result := RewriteWith(newCats(append(inputs, object)...), config)
+	return Behavior{inputs, result}
+}
+
+// holes returns boxed placeholders for the inputs of a program.
+func holes(count int) []Object {
+	const names = "XYZABCDEFGHIJKLMNOPQRSTUVW"
+	var boxes []Object
+	for i := 0; i < count; i++ {
+		name := string(names[i%len(names)])
+		if i >= len(names) {
+			name += fmt.Sprint(i / len(names))
+		}
+		boxes = append(boxes, newBox(mkHole{name}))
+	}
+	return boxes
+}
@@ -0,0 +1,69 @@
+package abc
+
+import (
+	"testing"
+)
+
+// The inputs of a program are counted from its inferred effect when
+// there is one, and otherwise by trying more inputs until it doesn't
+// get stuck. A program that runs one of its inputs gets stuck on the
+// placeholder.
+func TestSymbolic(t *testing.T) {
+	cases := []struct {
+		src    string
+		infers bool
+		stuck  bool
+		want   string
+	}{
+		{"", true, false, "->"},
+		{"[e]", true, false, "-> [e]"},
+		{"a", true, true, "[X] -> X"},
+		{"b", true, false, "[X] -> [[X]]"},
+		{"c", true, false, "[X] [Y] -> [X Y]"},
+		{"d", true, false, "[X] -> [X] [X]"},
+		{"e", true, false, "[X] ->"},
+		{"f", true, false, "[X] [Y] -> [Y] [X]"},
+		{"f a", true, true, "[X] [Y] -> [Y] X"},
+		{"b [e] c d a", true, false, "[X] -> [[X] e]"},
+		{"[d a] f e", false, false, "[X] -> [d a]"},
+		{"[d a] f e f", false, false, "[X] [Y] -> [d a] [X]"},
+		{"d a", false, true, "-> d a"},
+		{"#00ab", false, true, "-> #00ab"},
+	}
+	for _, test := range cases {
+		object := MustRead(test.src)
+		_, err := noWords.Infer(object)
+		if (err == nil) != test.infers {
+			t.Errorf("`%s`: expected inference to succeed to be %v, but got %v", test.src, test.infers, err)
+		}
+		stuck := false
+		config := Config{Quota: 1000, Loader: noWords}
+		config.Stuck = func(Stuck) { stuck = true }
+		got := Symbolic(object, config)
+		if got.String() != test.want {
+			t.Errorf("`%s`: expected `%s`, but got `%s`", test.src, test.want, got)
+		}
+		if stuck != test.stuck {
+			t.Errorf("`%s`: expected getting stuck to be %v", test.src, test.stuck)
+		}
+	}
+}
+
+func TestBehaviorString(t *testing.T) {
+	x, y := newBox(Hole("X")), newBox(Hole("Y"))
+	cases := []struct {
+		behavior Behavior
+		want     string
+	}{
+		{Behavior{nil, newCats()}, "->"},
+		{Behavior{nil, x}, "-> [X]"},
+		{Behavior{[]Object{x}, newCats()}, "[X] ->"},
+		{Behavior{[]Object{x, y}, newCats(Hole("Y"), x)}, "[X] [Y] -> Y [X]"},
+	}
+	for _, test := range cases {
+		got := test.behavior.String()
+		if got != test.want {
+			t.Errorf("expected `%s`, but got `%s`", test.want, got)
+		}
+	}
+}
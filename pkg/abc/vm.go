@@ -0,0 +1,418 @@
+/**
+This file is a part of ABC.
+Copyright (C) 2018 Matthew Blount
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but
+WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public
+License along with this program.  If not, see
+<https://www.gnu.org/licenses/>.
+**/
+
+package abc
+
+import ()
+
+// A Program is an object compiled to a flat array of instructions,
+// which can be rewritten faster than the object itself. The body of
+// every box is compiled to its own range of the array, and a box is
+// pushed by referring to its quotation, so running a program never
+// walks a tree of concatenations, and `c` joins quotations without
+// copying their code.
+//
+// A Program gives the same results as Rewrite, but doesn't trace the
+// words it expands. It may be run any number of times, including by
+// several goroutines at once.
+type Program struct {
+	code   []instr
+	objs   []Object
+	quotes []quote
+}
+
+// An instr is a single instruction: a primitive, the push of the
+// quotation arg, a word, an annotation, or any other object, which
+// is always stuck.
+type instr struct {
+	op  byte
+	arg int32
+}
+
+// A quote is the body of a box. It is either a range of compiled
+// code, a box wrapped by `b`, or two quotes joined by `c`. The box
+// is made from the quote when it's needed, and then kept.
+type quote struct {
+	kind     byte
+	start    int32
+	end      int32
+	fst, snd int32
+	src      *Origin
+	box      *mkBox
+}
+
+const (
+	quoteCode = iota
+	quoteWrap
+	quoteJoin
+)
+
+// Compile compiles an object, including the bodies of its boxes, but
+// not the definitions of its words, which are compiled as they are
+// loaded.
+func Compile(object Object) *Program {
+	prog := &Program{}
+	prog.compile(orEmpty(object), nil)
+	return prog
+}
+
+// compile appends a quote for some code, and for each box inside it,
+// without recursion. It returns the index of the first quote.
+func (prog *Program) compile(object Object, box *mkBox) int32 {
+	type task struct {
+		quote int32
+		body  Object
+	}
+	first := int32(len(prog.quotes))
+	prog.quotes = append(prog.quotes, quote{kind: quoteCode, box: box})
+	todo := []task{{first, object}}
+	for len(todo) > 0 {
+		next := todo[0]
+		todo = todo[1:]
+		start := int32(len(prog.code))
+		for _, part := range flat(next.body) {
+			op := primitive(part)
+			arg := int32(0)
+			switch part := part.(type) {
+			case *mkBox:
+				op = '['
+				arg = int32(len(prog.quotes))
+				prog.quotes = append(prog.quotes, quote{kind: quoteCode, box: part})
+				todo = append(todo, task{arg, part.body})
+			case mkVar:
+				op = 'w'
+			case mkNote:
+				op = 'n'
+			default:
+				if op == 0 {
+					op = '?'
+				}
+			}
+			prog.code = append(prog.code, instr{op, arg})
+			prog.objs = append(prog.objs, part)
+		}
+		prog.quotes[next.quote].start = start
+		prog.quotes[next.quote].end = int32(len(prog.code))
+	}
+	return first
+}
+
+// Rewrite runs a program until it either reaches a normal form or
+// the quota is exhausted, like Rewrite does for objects.
+func (prog *Program) Rewrite(quota int) Object {
+	return prog.RewriteWith(Config{Quota: quota})
+}
+
+// RewriteWith runs a program using the quota, loader and stuck
+// callback of a configuration. Like the default strategy, it leaves
+// the bodies of boxes alone.
+func (prog *Program) RewriteWith(config Config) Object {
+	loader := config.Loader
+	if loader == nil {
+		loader = defaultLoader
+	}
+	// Clip each slice, so that what the machine appends never
+	// overwrites another run's.
+	m := &machine{
+		Program: Program{
+			code:   prog.code[:len(prog.code):len(prog.code)],
+			objs:   prog.objs[:len(prog.objs):len(prog.objs)],
+			quotes: prog.quotes[:len(prog.quotes):len(prog.quotes)],
+		},
+		loader: loader,
+		report: config.Stuck,
+		words:  make(map[string]compiled),
+	}
+	if len(prog.quotes) > 0 {
+		m.apply(0)
+	}
+	busy := len(m.work) > 0
+	for quota := config.Quota; busy && quota > 0; quota-- {
+		busy = m.step()
+	}
+	return m.Object()
+}
+
+// A span is a range of code left to run. A span whose end is
+// negative instead applies the quote at pc, which is how the second
+// half of a joined quote waits for the first.
+type span struct {
+	pc  int32
+	end int32
+}
+
+// A compiled word remembers the definition it was compiled from, so
+// that a changed definition is compiled again.
+type compiled struct {
+	body  Object
+	quote int32
+}
+
+type machine struct {
+	Program
+	loader *Loader
+	report func(Stuck)
+	kill   []Object
+	data   []int32
+	work   []span
+	words  map[string]compiled
+}
+
+// apply pushes the work of applying a quote, and returns true, since
+// that is always progress.
+func (m *machine) apply(q int32) bool {
+	for {
+		quote := &m.quotes[q]
+		switch quote.kind {
+		case quoteWrap:
+			m.data = append(m.data, quote.fst)
+			return true
+		case quoteJoin:
+			m.work = append(m.work, span{quote.snd, -1})
+			q = quote.fst
+		default:
+			if quote.start < quote.end {
+				m.work = append(m.work, span{quote.start, quote.end})
+			}
+			return true
+		}
+	}
+}
+
+// step runs instructions until one of them makes progress, and
+// returns whether there is work left, like the step of a rewrite.
+func (m *machine) step() bool {
+	for len(m.work) > 0 {
+		top := &m.work[len(m.work)-1]
+		if top.end < 0 {
+			q := top.pc
+			m.work = m.work[:len(m.work)-1]
+			m.apply(q)
+			continue
+		}
+		pc := top.pc
+		top.pc++
+		if top.pc == top.end {
+			m.work = m.work[:len(m.work)-1]
+		}
+		if m.exec(pc) {
+			break
+		}
+	}
+	return len(m.work) > 0
+}
+
+// exec runs the instruction at pc, returning whether it made progress.
+func (m *machine) exec(pc int32) bool {
+	ins := m.code[pc]
+	n := len(m.data)
+	switch ins.op {
+	case '[':
+		m.data = append(m.data, ins.arg)
+		return false
+	case 'a':
+		if n < 1 {
+			break
+		}
+		q := m.data[n-1]
+		m.data = m.data[:n-1]
+		return m.apply(q)
+	case 'b':
+		if n < 1 {
+			break
+		}
+		src := OriginOf(m.objs[pc])
+		m.data[n-1] = m.quote(quote{kind: quoteWrap, fst: m.data[n-1], src: src})
+		return true
+	case 'c':
+		if n < 2 {
+			break
+		}
+		src := OriginOf(m.objs[pc])
+		join := quote{kind: quoteJoin, fst: m.data[n-2], snd: m.data[n-1], src: src}
+		m.data = m.data[:n-1]
+		m.data[n-2] = m.quote(join)
+		return true
+	case 'd':
+		if n < 1 {
+			break
+		}
+		m.data = append(m.data, m.data[n-1])
+		return true
+	case 'e':
+		if n < 1 {
+			break
+		}
+		m.data = m.data[:n-1]
+		return true
+	case 'f':
+		if n < 2 {
+			break
+		}
+		m.data[n-1], m.data[n-2] = m.data[n-2], m.data[n-1]
+		return true
+	case 'w':
+		word := m.objs[pc].(mkVar)
+		if m.accelerate(word) {
+			return true
+		}
+		body, err := m.loader.Load(word.name)
+		if err != nil {
+			break
+		}
+		return m.apply(m.define(word.name, body))
+	case 'n':
+		return false
+	}
+	m.clear(pc)
+	return false
+}
+
+// accelerate runs the accelerator of a word, if it has one that can
+// run on the values on the stack. The values are made into boxes for
+// it, and any new boxes it returns are compiled.
+func (m *machine) accelerate(word mkVar) bool {
+	fn := m.loader.accelerator(word.name)
+	if fn == nil {
+		return false
+	}
+	stack := make([]Object, len(m.data))
+	known := make(map[*mkBox]int32)
+	for i, q := range m.data {
+		box := m.box(q)
+		stack[i] = box
+		known[box] = q
+	}
+	out, rest, ok := fn.run(stack)
+	if !ok {
+		return false
+	}
+	m.data = m.data[:0]
+	for _, object := range out {
+		box := object.(*mkBox)
+		q, ok := known[box]
+		if !ok {
+			q = m.compile(box.body, box)
+			known[box] = q
+		}
+		m.data = append(m.data, q)
+	}
+	return m.apply(m.compile(rest, nil))
+}
+
+// quote adds a quote made while running.
+func (m *machine) quote(quote quote) int32 {
+	m.quotes = append(m.quotes, quote)
+	return int32(len(m.quotes) - 1)
+}
+
+// define returns the quote of a word's definition, compiling it if
+// it hasn't been compiled since it last changed. Only definitions
+// that are concatenations or boxes can be told apart by identity;
+// others are small, and are compiled each time.
+func (m *machine) define(name string, body Object) int32 {
+	cached, ok := m.words[name]
+	if ok && same(cached.body, body) {
+		return cached.quote
+	}
+	q := m.Program.compile(body, nil)
+	m.words[name] = compiled{body, q}
+	return q
+}
+
+func same(fst, snd Object) bool {
+	switch fst.(type) {
+	case *mkCat, *mkBox:
+		return fst == snd
+	default:
+		return false
+	}
+}
+
+// clear gives up on the instruction at pc, as a rewrite would. The
+// object itself explains why it is stuck, by stepping in a rewrite
+// with the same values on its stack.
+func (m *machine) clear(pc int32) {
+	object := m.objs[pc]
+	if m.report != nil {
+		ctx := newRewrite(opId{}, &options{loader: m.loader, report: m.report})
+		for _, q := range m.data {
+			ctx.data.push(m.box(q))
+		}
+		object.step(ctx)
+	}
+	for _, q := range m.data {
+		m.kill = append(m.kill, m.box(q))
+	}
+	m.kill = append(m.kill, object)
+	m.data = m.data[:0]
+}
+
+// box returns the box of a quote, making it and the boxes it depends
+// on if need be, without recursion.
+func (m *machine) box(q int32) *mkBox {
+	todo := []int32{q}
+	for len(todo) > 0 {
+		next := todo[len(todo)-1]
+		quote := &m.quotes[next]
+		if quote.box != nil {
+			todo = todo[:len(todo)-1]
+			continue
+		}
+		switch quote.kind {
+		case quoteWrap:
+			fst := m.quotes[quote.fst].box
+			if fst == nil {
+				todo = append(todo, quote.fst)
+				continue
+			}
+			quote.box = &mkBox{fst, quote.src}
+		case quoteJoin:
+			fst, snd := m.quotes[quote.fst].box, m.quotes[quote.snd].box
+			if fst == nil || snd == nil {
+				todo = append(todo, quote.fst, quote.snd)
+				continue
+			}
+			quote.box = &mkBox{newCats(fst.body, snd.body), quote.src}
+		default:
+			body := newCats(m.objs[quote.start:quote.end]...)
+			quote.box = &mkBox{body, nil}
+		}
+		todo = todo[:len(todo)-1]
+	}
+	return m.quotes[q].box
+}
+
+// Object returns what is left of the program: the code that got
+// stuck, the values on the stack, and the code still to run.
+func (m *machine) Object() Object {
+	code := append([]Object(nil), m.kill...)
+	for _, q := range m.data {
+		code = append(code, m.box(q))
+	}
+	for i := len(m.work) - 1; i >= 0; i-- {
+		next := m.work[i]
+		if next.end < 0 {
+			code = append(code, flat(m.box(next.pc).body)...)
+			continue
+		}
+		code = append(code, m.objs[next.pc:next.end]...)
+	}
+	return newCats(code...)
+}
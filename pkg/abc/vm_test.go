@@ -0,0 +1,65 @@
+package abc
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// A compiled program gives the same result as Rewrite, whether or not
+// the quota runs out, and reports the same code as stuck.
+func TestCompileAgrees(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		src := randomProgram(r, r.Intn(12), 3)
+		quota := r.Intn(40)
+		if i%2 == 0 {
+			quota = 2000
+		}
+		var want, got []string
+		config := Config{Quota: quota, Loader: noWords}
+		config.Stuck = func(stuck Stuck) { want = append(want, stuck.String()) }
+		rewritten := RewriteWith(MustRead(src), config)
+		config.Stuck = func(stuck Stuck) { got = append(got, stuck.String()) }
+		compiled := Compile(MustRead(src)).RewriteWith(config)
+		if !Equals(rewritten, compiled) {
+			t.Fatalf("`%s` with a quota of %d: Rewrite gives `%s`, but the compiled program gives `%s`",
+				src, quota, rewritten, compiled)
+		}
+		if len(want) != len(got) {
+			t.Fatalf("`%s` with a quota of %d: Rewrite reports %q, but the compiled program reports %q",
+				src, quota, want, got)
+		}
+		for j := range want {
+			if want[j] != got[j] {
+				t.Fatalf("`%s` with a quota of %d: Rewrite reports %q, but the compiled program reports %q",
+					src, quota, want, got)
+			}
+		}
+	}
+}
+
+func BenchmarkRewrite(b *testing.B) {
+	config := Config{Quota: benchQuota, Loader: noWords}
+	for _, work := range readCorpus(b) {
+		work := work
+		b.Run(work.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				RewriteWith(work.Code, config)
+			}
+		})
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	config := Config{Quota: benchQuota, Loader: noWords}
+	for _, work := range readCorpus(b) {
+		prog := Compile(work.Code)
+		b.Run(work.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				prog.RewriteWith(config)
+			}
+		})
+	}
+}